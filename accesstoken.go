@@ -1,8 +1,11 @@
 package wechat
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/esap/wechat/util"
@@ -18,25 +21,47 @@ type AccessToken struct {
 	WxErr
 }
 
-// GetAccessToken 读取AccessToken
+// GetAccessToken 读取AccessToken，获取失败时重试3次，仍失败则返回空字符串
 func (s *Server) GetAccessToken() string {
+	var token string
+	var err error
+	for i := 0; i < 3; i++ {
+		token, err = s.GetAccessTokenCtx(context.Background())
+		if err == nil {
+			break
+		}
+		log.Printf("GetAccessToken[%v] %v", s.AgentId, err)
+		time.Sleep(time.Second)
+	}
+	return token
+}
+
+// GetAccessTokenCtx 读取AccessToken，支持ctx取消
+// 设置了ExternalTokenHandler时通过外部方法获取，否则由TokenManager缓存与刷新
+func (s *Server) GetAccessTokenCtx(ctx context.Context) (string, error) {
+	if s.ExternalTokenHandler == nil {
+		return s.TokenManager().Token(ctx)
+	}
 	s.Lock()
 	defer s.Unlock()
-	var err error
 	if s.accessToken == nil || s.accessToken.ExpiresIn < time.Now().Unix() {
-		for i := 0; i < 3; i++ {
-			err = s.getAccessToken()
-			if err == nil {
-				break
-			}
-			log.Printf("GetAccessToken[%v] %v", s.AgentId, err)
-			time.Sleep(time.Second)
-		}
-		if err != nil {
-			return ""
+		if err := s.getAccessToken(); err != nil {
+			return "", err
 		}
 	}
-	return s.accessToken.AccessToken
+	return s.accessToken.AccessToken, nil
+}
+
+// TokenManager 返回Server使用的access_token管理器
+func (s *Server) TokenManager() *TokenManager {
+	s.Lock()
+	defer s.Unlock()
+	if s.tokens == nil {
+		s.tokens = NewTokenManager(s.AppId, s.Secret, s.TokenStore)
+		s.tokens.TokenUrl = s.TokenUrl
+		s.tokens.Stable = s.StableToken && !s.EntMode
+	}
+	return s.tokens
 }
 
 // GetUserAccessToken 获取企业微信通讯录AccessToken
@@ -47,25 +72,15 @@ func (s *Server) GetUserAccessToken() string {
 	return s.GetAccessToken()
 }
 
+// getAccessToken 通过ExternalTokenHandler获取token
 func (s *Server) getAccessToken() (err error) {
-	if s.ExternalTokenHandler != nil {
-		s.accessToken = s.ExternalTokenHandler(s.AppId, s.AppName)
-		Printf("***%v[%v]远程获取token:%v", util.Substr(s.AppId, 14, 30), s.AgentId, s.accessToken)
-		return
+	at := s.ExternalTokenHandler(s.AppId, s.AppName)
+	if at == nil {
+		return errors.New("ExternalTokenHandler returned nil")
 	}
-	url := fmt.Sprintf(s.TokenUrl, s.AppId, s.Secret)
-	at := new(AccessToken)
-	if err = util.GetJson(url, at); err != nil {
-		return
-	}
-	if at.ErrCode > 0 {
-		return at.Error()
-	}
-	at.ExpiresIn = time.Now().Unix() + at.ExpiresIn - 5
 	s.accessToken = at
-	Printf("***%v[%v]本地获取token:%v", util.Substr(s.AppId, 14, 30), s.AgentId, s.accessToken)
+	Printf("***%v[%v]远程获取token:%v", util.Substr(s.AppId, 14, 30), s.AgentId, s.accessToken)
 	return
-
 }
 
 // Ticket JS-SDK
@@ -75,37 +90,60 @@ type Ticket struct {
 	WxErr
 }
 
-// GetTicket 读取获取Ticket
+// GetTicket 读取获取Ticket，获取失败时重试3次
 func (s *Server) GetTicket() string {
-	if s.ticket == nil || s.ticket.ExpiresIn < time.Now().Unix() {
-		for i := 0; i < 3; i++ {
-			err := s.getTicket()
-			if err != nil {
-				log.Printf("getTicket[%v] err:%v", s.AgentId, err)
-				time.Sleep(time.Second)
-				continue
-			}
+	var ticket string
+	var err error
+	for i := 0; i < 3; i++ {
+		ticket, err = s.GetTicketCtx(context.Background())
+		if err == nil {
 			break
 		}
+		log.Printf("getTicket[%v] err:%v", s.AgentId, err)
+		time.Sleep(time.Second)
 	}
-	return s.ticket.Ticket
+	return ticket
 }
 
-func (s *Server) getTicket() (err error) {
-	url := s.JsApi + s.GetAccessToken()
-	at := new(Ticket)
-	if err = util.GetJson(url, at); err != nil {
-		return
+// GetTicketCtx 读取jsapi_ticket，缓存至过期前TokenRefreshAhead，支持ctx取消
+func (s *Server) GetTicketCtx(ctx context.Context) (string, error) {
+	s.ticketMu.Lock()
+	defer s.ticketMu.Unlock()
+	if s.ticket == nil || s.ticket.ExpiresIn < time.Now().Unix() {
+		if err := s.getTicket(ctx); err != nil {
+			return "", err
+		}
 	}
-	if at.ErrCode > 0 {
-		return at.Error()
+	return s.ticket.Ticket, nil
+}
+
+func (s *Server) getTicket(ctx context.Context) (err error) {
+	at, err := s.fetchTicket(ctx, s.JsApi)
+	if err != nil {
+		return
 	}
 	Printf("[%v::%v-JsApi] >>> %+v", s.AppId, s.AgentId, *at)
-	at.ExpiresIn = time.Now().Unix() + 500
 	s.ticket = at
 	return
 }
 
+// fetchTicket 获取ticket，ExpiresIn转换为提前TokenRefreshAhead过期的时间戳
+func (s *Server) fetchTicket(ctx context.Context, uri string) (*Ticket, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	at := new(Ticket)
+	if err = util.GetJsonCtx(ctx, uri+token, at); err != nil {
+		return nil, err
+	}
+	if at.ErrCode > 0 {
+		return nil, at.Error()
+	}
+	at.ExpiresIn = time.Now().Unix() + at.ExpiresIn - int64(TokenRefreshAhead/time.Second)
+	return at, nil
+}
+
 // JsConfig Jssdk配置
 type JsConfig struct {
 	Beta      bool     `json:"beta"`
@@ -119,16 +157,37 @@ type JsConfig struct {
 	App       int      `json:"jsapp"`
 }
 
-// GetJsConfig 获取Jssdk配置
+// GetJsConfig 获取Jssdk配置，获取jsapi_ticket失败时返回nil
 func (s *Server) GetJsConfig(Url string) *JsConfig {
+	jc, err := s.GetJsConfigCtx(context.Background(), Url)
+	if err != nil {
+		log.Printf("GetJsConfig[%v] err:%v", s.AgentId, err)
+	}
+	return jc
+}
+
+// GetJsConfigCtx 获取Jssdk配置，签名使用的url不含#及其后面部分，获取jsapi_ticket失败时返回错误
+func (s *Server) GetJsConfigCtx(ctx context.Context, Url string) (*JsConfig, error) {
+	ticket, err := s.GetTicketCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
 	jc := &JsConfig{Beta: true, Debug: Debug, AppId: s.AppId}
 	jc.Timestamp = time.Now().Unix()
-	jc.Nonsestr = "esap"
-	jc.Signature = util.SortSha1(fmt.Sprintf("jsapi_ticket=%v&noncestr=%v&timestamp=%v&url=%v", s.GetTicket(), jc.Nonsestr, jc.Timestamp, Url))
+	jc.Nonsestr = util.GetRandomString(16)
+	if i := strings.IndexByte(Url, '#'); i >= 0 {
+		Url = Url[:i]
+	}
+	jc.Signature = JsSignature(ticket, jc.Nonsestr, jc.Timestamp, Url)
 	// TODO：可加入其他apilist
 	jc.JsApiList = []string{"scanQRCode"}
 	jc.Url = Url
 	jc.App = s.AgentId
 	Println("jsconfig:", jc) // Debug
-	return jc
+	return jc, nil
+}
+
+// JsSignature JS-SDK签名，对jsapi_ticket、noncestr、timestamp、url按字段名排序拼接后sha1
+func JsSignature(ticket, nonceStr string, timestamp int64, url string) string {
+	return util.SortSha1(fmt.Sprintf("jsapi_ticket=%v&noncestr=%v&timestamp=%v&url=%v", ticket, nonceStr, timestamp, url))
 }
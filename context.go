@@ -18,6 +18,7 @@ type Context struct {
 	Writer    http.ResponseWriter
 	Request   *http.Request
 	hasReply  bool
+	verified  bool // VerifyURL校验签名成功
 }
 
 // Reply 被动回复消息
@@ -137,7 +138,7 @@ func (c *Context) NewMpNews(mediaId string) *Context {
 func (c *Context) NewMusic(mediaId, title, desc, musicUrl, hqMusicUrl string) *Context {
 	c.Resp = &Music{
 		wxResp: c.newResp(TypeMusic),
-		Music:  music{CDATA(mediaId), CDATA(title), CDATA(desc), CDATA(musicUrl), CDATA(hqMusicUrl)}}
+		Music:  music{CDATA(title), CDATA(desc), CDATA(musicUrl), CDATA(hqMusicUrl), CDATA(mediaId)}}
 	return c
 }
 
@@ -0,0 +1,92 @@
+package wechat
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HandlerFunc 消息处理函数，可调用ctx.NewText(...).Reply()等被动回复
+type HandlerFunc func(ctx *Context)
+
+// Dispatcher 按MsgType及Event将回调消息分发到已注册的处理函数
+type Dispatcher struct {
+	Server *Server
+
+	mu       sync.RWMutex
+	msgs     map[string]HandlerFunc
+	events   map[string]HandlerFunc
+	fallback HandlerFunc
+}
+
+// NewDispatcher 创建消息分发器
+func NewDispatcher(s *Server) *Dispatcher {
+	return &Dispatcher{
+		Server: s,
+		msgs:   make(map[string]HandlerFunc),
+		events: make(map[string]HandlerFunc),
+	}
+}
+
+// HandleMsg 注册普通消息处理函数，msgType如TypeText、TypeImage、TypeLocation
+func (d *Dispatcher) HandleMsg(msgType string, h HandlerFunc) *Dispatcher {
+	d.mu.Lock()
+	d.msgs[strings.ToLower(msgType)] = h
+	d.mu.Unlock()
+	return d
+}
+
+// HandleEvent 注册事件处理函数，event如EventSubscribe、EventClick，不区分大小写
+func (d *Dispatcher) HandleEvent(event string, h HandlerFunc) *Dispatcher {
+	d.mu.Lock()
+	d.events[strings.ToLower(event)] = h
+	d.mu.Unlock()
+	return d
+}
+
+// HandleDefault 注册未匹配到处理函数时的默认处理
+func (d *Dispatcher) HandleDefault(h HandlerFunc) *Dispatcher {
+	d.mu.Lock()
+	d.fallback = h
+	d.mu.Unlock()
+	return d
+}
+
+// Dispatch 分发ctx.Msg，未找到处理函数时返回false
+func (d *Dispatcher) Dispatch(ctx *Context) bool {
+	if ctx == nil || ctx.Msg == nil || ctx.Msg.MsgType == "" {
+		return false
+	}
+	d.mu.RLock()
+	var h HandlerFunc
+	if ctx.Msg.MsgType == TypeEvent {
+		h = d.events[strings.ToLower(ctx.Msg.Event)]
+	} else {
+		h = d.msgs[strings.ToLower(ctx.Msg.MsgType)]
+	}
+	if h == nil {
+		h = d.fallback
+	}
+	d.mu.RUnlock()
+	if h == nil {
+		return false
+	}
+	h(ctx)
+	return true
+}
+
+// ServeHTTP 实现http.Handler：校验签名、解密(安全模式)并分发消息，处理函数未回复时返回success
+func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := d.Server.VerifyURL(w, r)
+	if r.Method != http.MethodPost {
+		return
+	}
+	if !ctx.verified {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	d.Dispatch(ctx)
+	if !ctx.hasReply {
+		w.Write([]byte("success"))
+	}
+}
@@ -0,0 +1,81 @@
+package wechat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/esap/wechat/util"
+)
+
+const testTextMsg = `<xml><ToUserName><![CDATA[gh_123]]></ToUserName><FromUserName><![CDATA[oUser]]></FromUserName>` +
+	`<CreateTime>1348831860</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hello]]></Content><MsgId>1234567890123456</MsgId></xml>`
+
+func TestParseMsg(t *testing.T) {
+	msg, err := ParseMsg([]byte(testTextMsg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.MsgType != TypeText || msg.Content != "hello" || msg.FromUserName != "oUser" || msg.MsgId != 1234567890123456 {
+		t.Errorf("unexpected msg: %+v", msg)
+	}
+
+	msg, err = ParseMsg([]byte(`{"ToUserName":"gh_123","FromUserName":"oUser","MsgType":"event","Event":"CLICK","EventKey":"V1001"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Event != EventClick || msg.EventKey != "V1001" {
+		t.Errorf("unexpected msg: %+v", msg)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var got string
+	d := NewDispatcher(&Server{}).
+		HandleMsg(TypeText, func(ctx *Context) { got = "text:" + ctx.Msg.Content }).
+		HandleEvent(EventClick, func(ctx *Context) { got = "click:" + ctx.Msg.EventKey })
+
+	cases := []struct {
+		msg  WxMsg
+		ok   bool
+		want string
+	}{
+		{WxMsg{MsgType: TypeText, Content: "hi"}, true, "text:hi"},
+		{WxMsg{MsgType: TypeEvent, Event: "click", EventKey: "K"}, true, "click:K"},
+		{WxMsg{MsgType: TypeImage}, false, ""},
+		{WxMsg{MsgType: TypeEvent, Event: EventSubscribe}, false, ""},
+	}
+	for _, c := range cases {
+		got = ""
+		msg := c.msg
+		if ok := d.Dispatch(&Context{Msg: &msg}); ok != c.ok || got != c.want {
+			t.Errorf("Dispatch(%+v) = %v, %q; want %v, %q", c.msg, ok, got, c.ok, c.want)
+		}
+	}
+
+	d.HandleDefault(func(ctx *Context) { got = "default" })
+	if !d.Dispatch(&Context{Msg: &WxMsg{MsgType: TypeImage}}) || got != "default" {
+		t.Errorf("default handler not called")
+	}
+}
+
+func TestDispatcherServeHTTP(t *testing.T) {
+	s := &Server{Token: msgTestToken, DataFormat: DataFormatXML}
+	d := NewDispatcher(s).HandleMsg(TypeText, func(ctx *Context) {
+		ctx.NewText("echo:", ctx.Msg.Content).Reply()
+	})
+
+	sig := util.SortSha1(msgTestToken, msgTestTs, msgTestNonce)
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?signature="+sig+"&timestamp="+msgTestTs+"&nonce="+msgTestNonce, strings.NewReader(testTextMsg)))
+	if body := w.Body.String(); !strings.Contains(body, "<Content><![CDATA[echo:hello]]></Content>") || !strings.Contains(body, "<ToUserName><![CDATA[oUser]]></ToUserName>") {
+		t.Errorf("unexpected reply: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?signature=bad&timestamp="+msgTestTs+"&nonce="+msgTestNonce, strings.NewReader(testTextMsg)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for bad signature, got %d", w.Code)
+	}
+}
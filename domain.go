@@ -0,0 +1,117 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 微信服务器IP接口
+const (
+	WXAPIApiDomainIp  = WXAPI + "get_api_domain_ip?access_token="
+	WXAPICallbackIp   = WXAPI + "getcallbackip?access_token="
+	WXAPINetworkCheck = WXAPI + "callback/check?access_token="
+)
+
+// 网络检测动作
+const (
+	NetworkCheckAll  = "all"  // 同时检测dns和ping
+	NetworkCheckDns  = "dns"  // 仅做域名解析
+	NetworkCheckPing = "ping" // 仅做ping检测
+)
+
+// 网络检测运营商
+const (
+	OperatorChinaNet = "CHINANET" // 电信出口
+	OperatorUnicom   = "UNICOM"   // 联通出口
+	OperatorCap      = "CAP"      // 腾讯自建出口
+	OperatorDefault  = "DEFAULT"  // 根据ip来选择运营商
+)
+
+// ApiDomainIpCtx 获取微信API接口的IP地址列表，用于出口防火墙白名单
+// 设置IpCacheTTL后在有效期内返回缓存，否则每次调用都请求微信
+func (s *Server) ApiDomainIpCtx(ctx context.Context) ([]string, error) {
+	return s.ipList(ctx, WXAPIApiDomainIp)
+}
+
+// CallbackIpCtx 获取微信回调服务器的IP地址列表，用于限制回调来源，列表会变化需定期更新
+// 按来源IP过滤回调时应设置IpCacheTTL，避免每个回调都请求微信
+func (s *Server) CallbackIpCtx(ctx context.Context) ([]string, error) {
+	return s.ipList(ctx, WXAPICallbackIp)
+}
+
+// ipCacheEntry 缓存的IP列表
+type ipCacheEntry struct {
+	ips []string
+	at  time.Time
+}
+
+// ipList 获取ip_list，IpCacheTTL>0时按uri缓存
+func (s *Server) ipList(ctx context.Context, uri string) ([]string, error) {
+	if s.IpCacheTTL > 0 {
+		s.ipMu.Lock()
+		e, ok := s.ipCache[uri]
+		s.ipMu.Unlock()
+		if ok && time.Since(e.at) < s.IpCacheTTL {
+			return append([]string(nil), e.ips...), nil
+		}
+	}
+	ret := new(struct {
+		WxErr
+		IpList []string `json:"ip_list"`
+	})
+	if err := s.getCtx(ctx, uri, ret); err != nil {
+		return nil, err
+	}
+	if s.IpCacheTTL > 0 {
+		s.ipMu.Lock()
+		if s.ipCache == nil {
+			s.ipCache = make(map[string]ipCacheEntry)
+		}
+		s.ipCache[uri] = ipCacheEntry{ips: append([]string(nil), ret.IpList...), at: time.Now()}
+		s.ipMu.Unlock()
+	}
+	return ret.IpList, nil
+}
+
+// NetworkDns 网络检测的域名解析结果
+type NetworkDns struct {
+	Ip           string `json:"ip"`
+	RealOperator string `json:"real_operator"`
+}
+
+// NetworkPing 网络检测的ping结果
+type NetworkPing struct {
+	Ip           string `json:"ip"`
+	FromOperator string `json:"from_operator"`
+	PackageLoss  string `json:"package_loss"`
+	Time         string `json:"time"`
+}
+
+// NetworkCheckResult 网络检测结果
+type NetworkCheckResult struct {
+	WxErr
+	Dns  []NetworkDns  `json:"dns"`
+	Ping []NetworkPing `json:"ping"`
+}
+
+// NetworkCheckCtx 从微信服务器检测回调URL的连通性，action为空时使用all，checkOperator为空时使用DEFAULT
+// 调用次数超限可通过util.IsErrCode(err, 45009)判断
+func (s *Server) NetworkCheckCtx(ctx context.Context, action, checkOperator string) (*NetworkCheckResult, error) {
+	if action == "" {
+		action = NetworkCheckAll
+	}
+	if checkOperator == "" {
+		checkOperator = OperatorDefault
+	}
+	switch action {
+	case NetworkCheckAll, NetworkCheckDns, NetworkCheckPing:
+	default:
+		return nil, errors.New("wechat: invalid network check action " + action)
+	}
+	ret := new(NetworkCheckResult)
+	if err := s.postCtx(ctx, WXAPINetworkCheck, map[string]string{"action": action, "check_operator": checkOperator}, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -1,8 +1,13 @@
 package wechat
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
+	"strings"
 
 	"github.com/esap/wechat/util"
 )
@@ -45,6 +50,82 @@ func (s *Server) MediaUpload(mediaType string, filename string, contentType stri
 	return
 }
 
+// MediaUploadCtx 临时素材上传，从r流式读取文件内容，media_id有效期3天，mediaType同MediaUpload
+func (s *Server) MediaUploadCtx(ctx context.Context, mediaType, filename string, r io.Reader) (*Media, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uri := fmt.Sprintf(s.RootUrl+WXAPIMediaUpload, token, url.QueryEscape(mediaType))
+	media := new(Media)
+	if err = s.uploadCtx(ctx, uri, []util.MultipartFormField{fileField("media", filename, r)}, media); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// openMedia 打开素材下载流，返回响应体及Content-Type
+// 微信出错时返回json错误，视频素材返回带video_url的json，此时自动跟随下载
+func openMedia(ctx context.Context, uri string) (io.ReadCloser, string, error) {
+	for hop := 0; ; hop++ {
+		resp, err := util.GetResponseCtx(ctx, uri)
+		if err != nil {
+			return nil, "", err
+		}
+		if err = util.CheckStatus(resp, uri); err != nil {
+			resp.Body.Close()
+			return nil, "", err
+		}
+		ct := resp.Header.Get("Content-Type")
+		if !strings.Contains(ct, "json") && !strings.HasPrefix(ct, "text/plain") {
+			return resp.Body, ct, nil
+		}
+		ret := new(struct {
+			WxErr
+			VideoUrl string `json:"video_url"`
+		})
+		err = json.NewDecoder(resp.Body).Decode(ret)
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		if err = ret.Error(); err != nil {
+			return nil, "", err
+		}
+		if ret.VideoUrl == "" || hop > 0 {
+			return nil, "", fmt.Errorf("wechat: unexpected media response, content-type=%v", ct)
+		}
+		uri = ret.VideoUrl
+	}
+}
+
+// GetMediaCtx 下载临时素材，返回body字节及Content-Type，视频素材自动跟随video_url下载
+func (s *Server) GetMediaCtx(ctx context.Context, mediaId string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	ct, err := s.GetMediaToWriterCtx(ctx, &buf, mediaId)
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), ct, nil
+}
+
+// GetMediaToWriterCtx 下载临时素材并写入w，返回Content-Type
+func (s *Server) GetMediaToWriterCtx(ctx context.Context, w io.Writer, mediaId string) (string, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	body, ct, err := openMedia(ctx, fmt.Sprintf(s.RootUrl+WXAPIMediaGet, token, url.QueryEscape(mediaId)))
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	if _, err = io.Copy(w, body); err != nil {
+		return "", err
+	}
+	return ct, nil
+}
+
 // GetMedia 下载临时素材
 func (s *Server) GetMedia(filename, mediaId string) error {
 	url := fmt.Sprintf(s.RootUrl+WXAPIMediaGet, s.GetAccessToken(), mediaId)
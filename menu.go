@@ -1,6 +1,8 @@
 package wechat
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/esap/wechat/util"
@@ -13,6 +15,25 @@ const (
 	WXAPIMenuDel = `menu/delete?access_token=%s&agentid=%d`
 )
 
+// 菜单按钮类型
+const (
+	ButtonClick           = "click"
+	ButtonView            = "view"
+	ButtonMiniProgram     = "miniprogram"
+	ButtonScancodePush    = "scancode_push"
+	ButtonScancodeWaitMsg = "scancode_waitmsg"
+	ButtonPicSysPhoto     = "pic_sysphoto"
+	ButtonPicPhotoOrAlbum = "pic_photo_or_album"
+	ButtonPicWeixin       = "pic_weixin"
+	ButtonLocationSelect  = "location_select"
+)
+
+// 菜单按钮数量上限
+const (
+	MenuMaxButton    = 3 // 一级菜单最多3个
+	MenuMaxSubButton = 5 // 每个一级菜单最多5个二级菜单
+)
+
 type (
 	// Button 按钮
 	Button struct {
@@ -75,3 +96,119 @@ func (s *Server) DelMenu() (err error) {
 	}
 	return e.Error()
 }
+
+// Validate 检查菜单按钮数量是否超出微信限制
+func (m *Menu) Validate() error {
+	if len(m.Button) == 0 {
+		return errors.New("menu: no button")
+	}
+	if len(m.Button) > MenuMaxButton {
+		return fmt.Errorf("menu: %d buttons, max %d", len(m.Button), MenuMaxButton)
+	}
+	for _, b := range m.Button {
+		if len(b.SubButton) > MenuMaxSubButton {
+			return fmt.Errorf("menu: button %q has %d sub buttons, max %d", b.Name, len(b.SubButton), MenuMaxSubButton)
+		}
+	}
+	return nil
+}
+
+// menuUrl 生成菜单接口地址
+func (s *Server) menuUrl(ctx context.Context, api string) (string, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(s.RootUrl+api, token, s.AgentId), nil
+}
+
+// GetMenuCtx 获取应用菜单，支持ctx取消
+func (s *Server) GetMenuCtx(ctx context.Context) (*Menu, error) {
+	url, err := s.menuUrl(ctx, WXAPIMenuGet)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Menu)
+	if err = s.getCtx(ctx, url, m); err != nil {
+		return nil, err
+	}
+	if len(m.Menu.Button) == 0 && len(m.Button) > 0 {
+		m.Menu.Button = m.Button
+	}
+	return m, nil
+}
+
+// AddMenuCtx 创建应用菜单，支持ctx取消，提交前检查按钮数量
+func (s *Server) AddMenuCtx(ctx context.Context, m *Menu) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	url, err := s.menuUrl(ctx, WXAPIMenuAdd)
+	if err != nil {
+		return err
+	}
+	return s.postCtx(ctx, url, m, nil)
+}
+
+// DelMenuCtx 删除应用菜单，支持ctx取消
+func (s *Server) DelMenuCtx(ctx context.Context) error {
+	url, err := s.menuUrl(ctx, WXAPIMenuDel)
+	if err != nil {
+		return err
+	}
+	return s.getCtx(ctx, url, nil)
+}
+
+// WXAPIMenuAddConditional 个性化菜单接口，仅服务号可用
+const (
+	WXAPIMenuAddConditional = WXAPI + "menu/addconditional?access_token="
+	WXAPIMenuDelConditional = WXAPI + "menu/delconditional?access_token="
+	WXAPIMenuTryMatch       = WXAPI + "menu/trymatch?access_token="
+)
+
+// MenuMatchRule 个性化菜单匹配规则，至少设置一项
+type MenuMatchRule struct {
+	TagId              string `json:"tag_id,omitempty"`
+	Sex                string `json:"sex,omitempty"` // 1-男，2-女
+	Country            string `json:"country,omitempty"`
+	Province           string `json:"province,omitempty"`
+	City               string `json:"city,omitempty"`
+	ClientPlatformType string `json:"client_platform_type,omitempty"` // 1-IOS，2-Android，3-Others
+	Language           string `json:"language,omitempty"`
+}
+
+// ConditionalMenu 个性化菜单
+type ConditionalMenu struct {
+	Button    []Button      `json:"button"`
+	MatchRule MenuMatchRule `json:"matchrule"`
+}
+
+// AddConditionalMenuCtx 创建个性化菜单，返回menuid
+func (s *Server) AddConditionalMenuCtx(ctx context.Context, m *ConditionalMenu) (menuId string, err error) {
+	if err = (&Menu{Button: m.Button}).Validate(); err != nil {
+		return
+	}
+	ret := new(struct {
+		WxErr
+		MenuId string `json:"menuid"`
+	})
+	if err = s.postCtx(ctx, WXAPIMenuAddConditional, m, ret); err != nil {
+		return
+	}
+	return ret.MenuId, nil
+}
+
+// DelConditionalMenuCtx 删除个性化菜单
+func (s *Server) DelConditionalMenuCtx(ctx context.Context, menuId string) error {
+	return s.postCtx(ctx, WXAPIMenuDelConditional, map[string]string{"menuid": menuId}, nil)
+}
+
+// TryMatchMenuCtx 测试个性化菜单匹配结果，userId可以是openid或微信号
+func (s *Server) TryMatchMenuCtx(ctx context.Context, userId string) (*Menu, error) {
+	m := new(Menu)
+	if err := s.postCtx(ctx, WXAPIMenuTryMatch, map[string]string{"user_id": userId}, m); err != nil {
+		return nil, err
+	}
+	m.Menu.Button = m.Button
+	return m, nil
+}
@@ -0,0 +1,38 @@
+package wechat
+
+import "context"
+
+// 公众号黑名单接口
+const (
+	WXAPIBlacklistGet   = WXAPI + "tags/members/getblacklist?access_token="
+	WXAPIBlacklistBatch = WXAPI + "tags/members/batchblacklist?access_token="
+	WXAPIBlacklistUndo  = WXAPI + "tags/members/batchunblacklist?access_token="
+)
+
+// MpBlacklistBatchMax 每次拉黑或取消拉黑的最大用户数
+const MpBlacklistBatchMax = 20
+
+// GetBlacklistCtx 获取黑名单列表，每次最多返回10000个，beginOpenId为空时从头开始，以返回的NextOpenId翻页
+func (s *Server) GetBlacklistCtx(ctx context.Context, beginOpenId string) (*MpUser, error) {
+	ret := new(MpUser)
+	if err := s.postCtx(ctx, WXAPIBlacklistGet, map[string]string{"begin_openid": beginOpenId}, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// BatchBlacklistCtx 批量拉黑用户，超过20个时自动分批，某批失败时停止并返回*BatchError
+func (s *Server) BatchBlacklistCtx(ctx context.Context, openIds []string) error {
+	return s.blacklistBatch(ctx, WXAPIBlacklistBatch, openIds)
+}
+
+// BatchUnblacklistCtx 批量取消拉黑用户，超过20个时自动分批，某批失败时停止并返回*BatchError
+func (s *Server) BatchUnblacklistCtx(ctx context.Context, openIds []string) error {
+	return s.blacklistBatch(ctx, WXAPIBlacklistUndo, openIds)
+}
+
+func (s *Server) blacklistBatch(ctx context.Context, uri string, openIds []string) error {
+	return batch(len(openIds), MpBlacklistBatchMax, func(start, end int) error {
+		return s.postCtx(ctx, uri, map[string][]string{"openid_list": openIds[start:end]}, nil)
+	})
+}
@@ -0,0 +1,406 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXCardAPI 公众号卡券接口，相关接口常量统一以此开头
+const (
+	WXCardAPI = "https://api.weixin.qq.com/card/"
+
+	WXAPICardCreate   = WXCardAPI + "create?access_token="
+	WXAPICardBatchGet = WXCardAPI + "batchget?access_token="
+	WXAPICardUpdate   = WXCardAPI + "update?access_token="
+	WXAPICardDelete   = WXCardAPI + "delete?access_token="
+
+	WXAPICardCodeConsume = WXCardAPI + "code/consume?access_token="
+	WXAPICardCodeDecrypt = WXCardAPI + "code/decrypt?access_token="
+
+	WXAPICardTicket = WXAPI + "ticket/getticket?type=wx_card&access_token="
+)
+
+// 卡券状态，用于BatchGetCardCtx过滤
+const (
+	CardStatusNotVerify  = "CARD_STATUS_NOT_VERIFY"  // 待审核
+	CardStatusVerifyFail = "CARD_STATUS_VERIFY_FAIL" // 审核失败
+	CardStatusVerifyOk   = "CARD_STATUS_VERIFY_OK"   // 通过审核
+	CardStatusDelete     = "CARD_STATUS_DELETE"      // 已删除
+	CardStatusDispatch   = "CARD_STATUS_DISPATCH"    // 已投放
+)
+
+// CardBatchGetMax 每次批量查询的最大卡券数
+const CardBatchGetMax = 50
+
+// 卡券类型
+const (
+	CardTypeCash          = "CASH"           // 代金券
+	CardTypeDiscount      = "DISCOUNT"       // 折扣券
+	CardTypeGift          = "GIFT"           // 兑换券
+	CardTypeGroupon       = "GROUPON"        // 团购券
+	CardTypeGeneralCoupon = "GENERAL_COUPON" // 优惠券
+	CardTypeMemberCard    = "MEMBER_CARD"    // 会员卡
+)
+
+// 卡券码型
+const (
+	CardCodeText    = "CODE_TYPE_TEXT"
+	CardCodeBarcode = "CODE_TYPE_BARCODE"
+	CardCodeQrcode  = "CODE_TYPE_QRCODE"
+	CardCodeOnlyQr  = "CODE_TYPE_ONLY_QRCODE"
+	CardCodeNone    = "CODE_TYPE_NONE"
+)
+
+// 卡券有效期类型
+const (
+	CardDateFixTimeRange = "DATE_TYPE_FIX_TIME_RANGE" // 固定日期区间
+	CardDateFixTerm      = "DATE_TYPE_FIX_TERM"       // 领取后固定天数
+	CardDatePermanent    = "DATE_TYPE_PERMANENT"      // 永久有效，仅会员卡可用
+)
+
+// CardDateInfo 卡券有效期
+type CardDateInfo struct {
+	Type           string `json:"type"`
+	BeginTimestamp int64  `json:"begin_timestamp,omitempty"` // 仅固定日期区间
+	EndTimestamp   int64  `json:"end_timestamp,omitempty"`
+	FixedTerm      int    `json:"fixed_term,omitempty"`       // 领取后多少天有效
+	FixedBeginTerm int    `json:"fixed_begin_term,omitempty"` // 领取后多少天开始生效，0为当天
+}
+
+// CardSku 卡券库存
+type CardSku struct {
+	Quantity int `json:"quantity"` // 上限100000000
+}
+
+// CardBaseInfo 卡券基础信息
+type CardBaseInfo struct {
+	LogoUrl        string       `json:"logo_url"`   // 须为上传图片接口返回的url
+	BrandName      string       `json:"brand_name"` // 商户名字，最多12个汉字
+	CodeType       string       `json:"code_type"`
+	Title          string       `json:"title"` // 卡券名，最多9个汉字
+	Color          string       `json:"color"` // 颜色名称，如Color010
+	Notice         string       `json:"notice"`
+	ServicePhone   string       `json:"service_phone,omitempty"`
+	Description    string       `json:"description"`
+	DateInfo       CardDateInfo `json:"date_info"`
+	Sku            CardSku      `json:"sku"`
+	UseLimit       int          `json:"use_limit,omitempty"`
+	GetLimit       int          `json:"get_limit,omitempty"`
+	UseCustomCode  bool         `json:"use_custom_code,omitempty"`
+	BindOpenid     bool         `json:"bind_openid,omitempty"`
+	CanShare       *bool        `json:"can_share,omitempty"`
+	CanGiveFriend  *bool        `json:"can_give_friend,omitempty"`
+	LocationIdList []int64      `json:"location_id_list,omitempty"`
+
+	CenterTitle       string `json:"center_title,omitempty"`
+	CenterSubTitle    string `json:"center_sub_title,omitempty"`
+	CenterUrl         string `json:"center_url,omitempty"`
+	CustomUrlName     string `json:"custom_url_name,omitempty"`
+	CustomUrl         string `json:"custom_url,omitempty"`
+	CustomUrlSubTitle string `json:"custom_url_sub_title,omitempty"`
+	PromotionUrlName  string `json:"promotion_url_name,omitempty"`
+	PromotionUrl      string `json:"promotion_url,omitempty"`
+	Source            string `json:"source,omitempty"`
+}
+
+// CardCash 代金券，金额单位为分
+type CardCash struct {
+	BaseInfo   *CardBaseInfo `json:"base_info"`
+	LeastCost  int           `json:"least_cost"` // 起用金额，0为无门槛
+	ReduceCost int           `json:"reduce_cost"`
+}
+
+// CardDiscount 折扣券
+type CardDiscount struct {
+	BaseInfo *CardBaseInfo `json:"base_info"`
+	Discount int           `json:"discount"` // 打折额度(百分比)，填30即七折
+}
+
+// CardGift 兑换券
+type CardGift struct {
+	BaseInfo *CardBaseInfo `json:"base_info"`
+	Gift     string        `json:"gift"`
+}
+
+// CardGroupon 团购券
+type CardGroupon struct {
+	BaseInfo   *CardBaseInfo `json:"base_info"`
+	DealDetail string        `json:"deal_detail"`
+}
+
+// CardGeneralCoupon 优惠券
+type CardGeneralCoupon struct {
+	BaseInfo      *CardBaseInfo `json:"base_info"`
+	DefaultDetail string        `json:"default_detail"`
+}
+
+// CardMember 会员卡
+type CardMember struct {
+	BaseInfo         *CardBaseInfo `json:"base_info"`
+	BackgroundPicUrl string        `json:"background_pic_url,omitempty"`
+	Prerogative      string        `json:"prerogative"` // 会员卡特权说明
+	AutoActivate     bool          `json:"auto_activate,omitempty"`
+	ActivateUrl      string        `json:"activate_url,omitempty"`
+	SupplyBonus      bool          `json:"supply_bonus"`
+	BonusUrl         string        `json:"bonus_url,omitempty"`
+	SupplyBalance    bool          `json:"supply_balance"`
+	BalanceUrl       string        `json:"balance_url,omitempty"`
+	Discount         int           `json:"discount,omitempty"`
+}
+
+// CardConfig 创建卡券的配置，按CardType填写对应类型的字段
+type CardConfig struct {
+	CardType      string             `json:"card_type"`
+	Cash          *CardCash          `json:"cash,omitempty"`
+	Discount      *CardDiscount      `json:"discount,omitempty"`
+	Gift          *CardGift          `json:"gift,omitempty"`
+	Groupon       *CardGroupon       `json:"groupon,omitempty"`
+	GeneralCoupon *CardGeneralCoupon `json:"general_coupon,omitempty"`
+	MemberCard    *CardMember        `json:"member_card,omitempty"`
+}
+
+// baseInfo 返回CardType对应类型的基础信息，类型字段未填写时返回nil
+func (c *CardConfig) baseInfo() *CardBaseInfo {
+	switch c.CardType {
+	case CardTypeCash:
+		if c.Cash != nil {
+			return c.Cash.BaseInfo
+		}
+	case CardTypeDiscount:
+		if c.Discount != nil {
+			return c.Discount.BaseInfo
+		}
+	case CardTypeGift:
+		if c.Gift != nil {
+			return c.Gift.BaseInfo
+		}
+	case CardTypeGroupon:
+		if c.Groupon != nil {
+			return c.Groupon.BaseInfo
+		}
+	case CardTypeGeneralCoupon:
+		if c.GeneralCoupon != nil {
+			return c.GeneralCoupon.BaseInfo
+		}
+	case CardTypeMemberCard:
+		if c.MemberCard != nil {
+			return c.MemberCard.BaseInfo
+		}
+	}
+	return nil
+}
+
+// Validate 校验卡券类型与对应字段及基础信息的必填项
+func (c *CardConfig) Validate() error {
+	b := c.baseInfo()
+	if b == nil {
+		return fmt.Errorf("wechat: card type %v missing payload or base_info", c.CardType)
+	}
+	if b.LogoUrl == "" || b.BrandName == "" || b.Title == "" || b.CodeType == "" || b.Color == "" || b.Notice == "" || b.Description == "" {
+		return errors.New("wechat: card base_info requires logo_url, brand_name, title, code_type, color, notice and description")
+	}
+	if b.DateInfo.Type == "" {
+		return errors.New("wechat: card date_info.type required")
+	}
+	return nil
+}
+
+// CreateCardCtx 创建卡券，返回card_id，创建后需审核通过才能投放
+func (s *Server) CreateCardCtx(ctx context.Context, card *CardConfig) (string, error) {
+	if err := card.Validate(); err != nil {
+		return "", err
+	}
+	ret := new(struct {
+		WxErr
+		CardId string `json:"card_id"`
+	})
+	if err := s.postCtx(ctx, WXAPICardCreate, map[string]*CardConfig{"card": card}, ret); err != nil {
+		return "", err
+	}
+	return ret.CardId, nil
+}
+
+// CardList 批量查询卡券结果
+type CardList struct {
+	WxErr
+	CardIdList []string `json:"card_id_list"`
+	TotalNum   int      `json:"total_num"`
+}
+
+// BatchGetCardCtx 批量查询卡券id，count为1-50，statusList为空时不过滤
+func (s *Server) BatchGetCardCtx(ctx context.Context, offset, count int, statusList []string) (*CardList, error) {
+	if count <= 0 || count > CardBatchGetMax {
+		return nil, fmt.Errorf("wechat: count %d must be in 1-%d", count, CardBatchGetMax)
+	}
+	req := struct {
+		Offset     int      `json:"offset"`
+		Count      int      `json:"count"`
+		StatusList []string `json:"status_list,omitempty"`
+	}{offset, count, statusList}
+	ret := new(CardList)
+	if err := s.postCtx(ctx, WXAPICardBatchGet, &req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CardUpdateBaseInfo 可修改的卡券基础信息，仅编码非空字段
+type CardUpdateBaseInfo struct {
+	LogoUrl           string        `json:"logo_url,omitempty"`
+	Title             string        `json:"title,omitempty"`
+	Color             string        `json:"color,omitempty"`
+	Notice            string        `json:"notice,omitempty"`
+	ServicePhone      string        `json:"service_phone,omitempty"`
+	Description       string        `json:"description,omitempty"`
+	CodeType          string        `json:"code_type,omitempty"`
+	DateInfo          *CardDateInfo `json:"date_info,omitempty"` // 有效期只能延长
+	GetLimit          int           `json:"get_limit,omitempty"`
+	CanShare          *bool         `json:"can_share,omitempty"`
+	CanGiveFriend     *bool         `json:"can_give_friend,omitempty"`
+	LocationIdList    []int64       `json:"location_id_list,omitempty"`
+	CenterTitle       string        `json:"center_title,omitempty"`
+	CenterSubTitle    string        `json:"center_sub_title,omitempty"`
+	CenterUrl         string        `json:"center_url,omitempty"`
+	CustomUrlName     string        `json:"custom_url_name,omitempty"`
+	CustomUrl         string        `json:"custom_url,omitempty"`
+	CustomUrlSubTitle string        `json:"custom_url_sub_title,omitempty"`
+	PromotionUrlName  string        `json:"promotion_url_name,omitempty"`
+	PromotionUrl      string        `json:"promotion_url,omitempty"`
+}
+
+// CardUpdate 修改卡券，CardType为卡券创建时的类型，Extra为该类型的专有字段，如会员卡的prerogative
+type CardUpdate struct {
+	CardType string
+	BaseInfo *CardUpdateBaseInfo
+	Extra    map[string]interface{}
+}
+
+// UpdateCardCtx 修改卡券信息，返回是否需要重新审核
+func (s *Server) UpdateCardCtx(ctx context.Context, cardId string, patch *CardUpdate) (sendCheck bool, err error) {
+	if cardId == "" || patch.CardType == "" {
+		return false, errors.New("wechat: card_id and card_type required")
+	}
+	payload := make(map[string]interface{}, len(patch.Extra)+1)
+	for k, v := range patch.Extra {
+		payload[k] = v
+	}
+	if patch.BaseInfo != nil {
+		payload["base_info"] = patch.BaseInfo
+	}
+	req := map[string]interface{}{
+		"card_id":                       cardId,
+		strings.ToLower(patch.CardType): payload,
+	}
+	ret := new(struct {
+		WxErr
+		SendCheck bool `json:"send_check"`
+	})
+	if err = s.postCtx(ctx, WXAPICardUpdate, req, ret); err != nil {
+		return
+	}
+	return ret.SendCheck, nil
+}
+
+// DeleteCardCtx 删除卡券，删除后不可恢复，已被用户领取的卡券不受影响
+func (s *Server) DeleteCardCtx(ctx context.Context, cardId string) error {
+	return s.postCtx(ctx, WXAPICardDelete, map[string]string{"card_id": cardId}, nil)
+}
+
+// CardConsumeResult 核销结果
+type CardConsumeResult struct {
+	WxErr
+	Card struct {
+		CardId string `json:"card_id"`
+	} `json:"card"`
+	OpenId string `json:"openid"` // 核销的用户
+}
+
+// ConsumeCardCodeCtx 核销卡券code，cardId仅在自定义code卡券时必填
+// code已核销或不存在可通过util.IsErrCode(err, 40099)、util.IsErrCode(err, 40056)判断
+func (s *Server) ConsumeCardCodeCtx(ctx context.Context, code, cardId string) (*CardConsumeResult, error) {
+	if code == "" {
+		return nil, errors.New("wechat: empty card code")
+	}
+	req := struct {
+		Code   string `json:"code"`
+		CardId string `json:"card_id,omitempty"`
+	}{code, cardId}
+	ret := new(CardConsumeResult)
+	if err := s.postCtx(ctx, WXAPICardCodeConsume, &req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DecryptCardCodeCtx 解码JS-SDK或卡券跳转链接中的encrypt_code，返回真实code
+func (s *Server) DecryptCardCodeCtx(ctx context.Context, encryptCode string) (string, error) {
+	if encryptCode == "" {
+		return "", errors.New("wechat: empty encrypt_code")
+	}
+	ret := new(struct {
+		WxErr
+		Code string `json:"code"`
+	})
+	if err := s.postCtx(ctx, WXAPICardCodeDecrypt, map[string]string{"encrypt_code": encryptCode}, ret); err != nil {
+		return "", err
+	}
+	return ret.Code, nil
+}
+
+// GetCardTicketCtx 读取卡券api_ticket(type=wx_card)，与jsapi_ticket不同，缓存至过期前TokenRefreshAhead
+func (s *Server) GetCardTicketCtx(ctx context.Context) (string, error) {
+	s.ticketMu.Lock()
+	defer s.ticketMu.Unlock()
+	if s.cardTicket == nil || s.cardTicket.ExpiresIn < time.Now().Unix() {
+		at, err := s.fetchTicket(ctx, WXAPICardTicket)
+		if err != nil {
+			return "", err
+		}
+		s.cardTicket = at
+	}
+	return s.cardTicket.Ticket, nil
+}
+
+// CardExt JS-SDK wx.addCard的cardExt参数，需用String()转为json字符串传入
+type CardExt struct {
+	Code      string `json:"code,omitempty"`
+	OpenId    string `json:"openid,omitempty"`
+	Timestamp string `json:"timestamp"`
+	NonceStr  string `json:"nonce_str"`
+	Signature string `json:"signature"`
+}
+
+// String 返回cardExt的json字符串
+func (e *CardExt) String() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+// CardSignature 卡券签名，对api_ticket、timestamp、card_id、code、openid、nonce_str的值排序拼接后sha1
+func CardSignature(ticket, cardId, code, openId, nonceStr, timestamp string) string {
+	return util.SortSha1(ticket, timestamp, cardId, code, openId, nonceStr)
+}
+
+// JsAddCardSignCtx 生成wx.addCard所需的cardExt，不指定code及openid，需要指定时可用CardSignature自行签名
+func (s *Server) JsAddCardSignCtx(ctx context.Context, cardId string) (*CardExt, error) {
+	if cardId == "" {
+		return nil, errors.New("wechat: empty card_id")
+	}
+	ticket, err := s.GetCardTicketCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e := &CardExt{
+		Timestamp: strconv.FormatInt(time.Now().Unix(), 10),
+		NonceStr:  util.GetRandomString(16),
+	}
+	e.Signature = CardSignature(ticket, cardId, e.Code, e.OpenId, e.NonceStr, e.Timestamp)
+	return e, nil
+}
@@ -0,0 +1,21 @@
+package wechat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCardSignature(t *testing.T) {
+	got := CardSignature("sM4AOVdWfPE4DxkXGEs8VMCPGGVi4C3VM0P37wVUCFvkVAy_90u5h9nbSlYy3-Sl-HhTdfl2fzFy1AOcHKP7qg",
+		"pXch-jnOlGtbuWwIO2NDftZeynRE", "jonyqin_1434008071", "p1Pj9jr90_SQRaVqYI239Ka1erkI", "wVYbpv9oRDpnmbHe", "1404896688")
+	if want := "55720001610092a5a93ae65ee88ba113e7044c11"; got != want {
+		t.Errorf("CardSignature = %v, want %v", got, want)
+	}
+}
+
+func TestCardExtString(t *testing.T) {
+	e := &CardExt{Timestamp: "1404896688", NonceStr: "n", Signature: "s"}
+	if got := e.String(); got != `{"timestamp":"1404896688","nonce_str":"n","signature":"s"}` || strings.Contains(got, "openid") {
+		t.Errorf("CardExt.String() = %v", got)
+	}
+}
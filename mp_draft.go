@@ -0,0 +1,75 @@
+package wechat
+
+import "context"
+
+// WXAPIDraftAdd 草稿箱接口
+const (
+	WXAPIDraftAdd    = WXAPI + "draft/add?access_token="
+	WXAPIDraftGet    = WXAPI + "draft/get?access_token="
+	WXAPIDraftUpdate = WXAPI + "draft/update?access_token="
+	WXAPIDraftDel    = WXAPI + "draft/delete?access_token="
+	WXAPIDraftCount  = WXAPI + "draft/count?access_token="
+)
+
+// MpDraftArticle 草稿图文，Content中的html不会被转义
+type MpDraftArticle struct {
+	Title              string `json:"title"`
+	Author             string `json:"author,omitempty"`
+	Digest             string `json:"digest,omitempty"`
+	Content            string `json:"content"`
+	ContentSourceUrl   string `json:"content_source_url,omitempty"`
+	ThumbMediaId       string `json:"thumb_media_id"`
+	ShowCoverPic       int    `json:"show_cover_pic,omitempty"` // 是否显示封面，1-显示
+	NeedOpenComment    int    `json:"need_open_comment,omitempty"`
+	OnlyFansCanComment int    `json:"only_fans_can_comment,omitempty"`
+	PicCrop2351        string `json:"pic_crop_235_1,omitempty"`
+	PicCrop11          string `json:"pic_crop_1_1,omitempty"`
+	Url                string `json:"url,omitempty"` // 草稿的临时链接，仅获取草稿时返回
+}
+
+// AddDraftCtx 新建草稿，返回草稿的media_id
+func (s *Server) AddDraftCtx(ctx context.Context, articles []MpDraftArticle) (mediaId string, err error) {
+	ret := new(struct {
+		WxErr
+		MediaId string `json:"media_id"`
+	})
+	if err = s.postCtx(ctx, WXAPIDraftAdd, map[string]interface{}{"articles": articles}, ret); err != nil {
+		return
+	}
+	return ret.MediaId, nil
+}
+
+// GetDraftCtx 获取草稿
+func (s *Server) GetDraftCtx(ctx context.Context, mediaId string) ([]MpDraftArticle, error) {
+	ret := new(struct {
+		WxErr
+		NewsItem []MpDraftArticle `json:"news_item"`
+	})
+	if err := s.postCtx(ctx, WXAPIDraftGet, map[string]string{"media_id": mediaId}, ret); err != nil {
+		return nil, err
+	}
+	return ret.NewsItem, nil
+}
+
+// UpdateDraftCtx 修改草稿中第index篇文章，index从0开始
+func (s *Server) UpdateDraftCtx(ctx context.Context, mediaId string, index int, article *MpDraftArticle) error {
+	req := map[string]interface{}{"media_id": mediaId, "index": index, "articles": article}
+	return s.postCtx(ctx, WXAPIDraftUpdate, req, nil)
+}
+
+// DelDraftCtx 删除草稿
+func (s *Server) DelDraftCtx(ctx context.Context, mediaId string) error {
+	return s.postCtx(ctx, WXAPIDraftDel, map[string]string{"media_id": mediaId}, nil)
+}
+
+// DraftCountCtx 获取草稿总数
+func (s *Server) DraftCountCtx(ctx context.Context) (int, error) {
+	ret := new(struct {
+		WxErr
+		TotalCount int `json:"total_count"`
+	})
+	if err := s.getCtx(ctx, WXAPIDraftCount, ret); err != nil {
+		return 0, err
+	}
+	return ret.TotalCount, nil
+}
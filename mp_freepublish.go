@@ -0,0 +1,67 @@
+package wechat
+
+import "context"
+
+// WXAPIFreePublishSubmit 发布接口
+const (
+	WXAPIFreePublishSubmit = WXAPI + "freepublish/submit?access_token="
+	WXAPIFreePublishGet    = WXAPI + "freepublish/get?access_token="
+	WXAPIFreePublishDel    = WXAPI + "freepublish/delete?access_token="
+)
+
+// 发布状态
+const (
+	PublishSuccess      = 0 // 成功
+	PublishPublishing   = 1 // 发布中
+	PublishOriginalFail = 2 // 原创失败
+	PublishFail         = 3 // 常规失败
+	PublishAuditFail    = 4 // 平台审核不通过
+	PublishDeleted      = 5 // 成功后用户删除所有文章
+	PublishBanned       = 6 // 成功后系统封禁所有文章
+)
+
+// MpPublishStatus 发布状态
+type MpPublishStatus struct {
+	WxErr
+	PublishId     string `json:"publish_id"`
+	PublishStatus int    `json:"publish_status"`
+	ArticleId     string `json:"article_id"` // 发布成功时返回，用于删除
+	ArticleDetail struct {
+		Count int `json:"count"`
+		Item  []struct {
+			Idx        int    `json:"idx"`
+			ArticleUrl string `json:"article_url"`
+		} `json:"item"`
+	} `json:"article_detail"`
+	FailIdx []int `json:"fail_idx"` // 原创失败、审核不通过的文章编号，从1开始
+}
+
+// FreePublishSubmitCtx 发布草稿，返回publish_id，发布结果以事件推送或通过FreePublishStatusCtx查询
+func (s *Server) FreePublishSubmitCtx(ctx context.Context, mediaId string) (publishId string, err error) {
+	ret := new(struct {
+		WxErr
+		PublishId string `json:"publish_id"`
+	})
+	if err = s.postCtx(ctx, WXAPIFreePublishSubmit, map[string]string{"media_id": mediaId}, ret); err != nil {
+		return
+	}
+	return ret.PublishId, nil
+}
+
+// FreePublishStatusCtx 查询发布状态
+func (s *Server) FreePublishStatusCtx(ctx context.Context, publishId string) (*MpPublishStatus, error) {
+	ps := new(MpPublishStatus)
+	if err := s.postCtx(ctx, WXAPIFreePublishGet, map[string]string{"publish_id": publishId}, ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// FreePublishDelCtx 删除发布的文章，index为要删除的文章编号，从1开始，为0时删除全部
+func (s *Server) FreePublishDelCtx(ctx context.Context, articleId string, index int) error {
+	req := map[string]interface{}{"article_id": articleId}
+	if index > 0 {
+		req["index"] = index
+	}
+	return s.postCtx(ctx, WXAPIFreePublishDel, req, nil)
+}
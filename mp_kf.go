@@ -0,0 +1,237 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXKFAPI 公众号客服接口，相关接口常量统一以此开头
+const (
+	WXKFAPI = "https://api.weixin.qq.com/customservice/"
+
+	WXAPIKfAccountAdd     = WXKFAPI + "kfaccount/add?access_token="
+	WXAPIKfAccountUpdate  = WXKFAPI + "kfaccount/update?access_token="
+	WXAPIKfAccountDel     = WXKFAPI + "kfaccount/del?access_token=%s&kf_account=%s"
+	WXAPIKfAccountHeadImg = WXKFAPI + "kfaccount/uploadheadimg?access_token=%s&kf_account=%s"
+	WXAPIKfList           = WXAPI + "customservice/getkflist?access_token="
+
+	WXAPIKfSessionCreate  = WXKFAPI + "kfsession/create?access_token="
+	WXAPIKfSessionClose   = WXKFAPI + "kfsession/close?access_token="
+	WXAPIKfSessionGet     = WXKFAPI + "kfsession/getsession?access_token=%s&openid=%s"
+	WXAPIKfSessionList    = WXKFAPI + "kfsession/getsessionlist?access_token=%s&kf_account=%s"
+	WXAPIKfSessionWaiting = WXKFAPI + "kfsession/getwaitcase?access_token="
+
+	WXAPIKfMsgList = WXKFAPI + "msgrecord/getmsglist?access_token="
+	WXAPIKfTyping  = WXAPI + "message/custom/typing?access_token="
+)
+
+// MpKfMsgRecordMax 每次获取聊天记录的最大条数
+const MpKfMsgRecordMax = 10000
+
+// MpKfAccount 客服账号
+type MpKfAccount struct {
+	KfAccount        string `json:"kf_account"` // 格式为 账号前缀@公众号微信号
+	KfNick           string `json:"kf_nick"`
+	KfId             string `json:"kf_id"`
+	KfHeadImgUrl     string `json:"kf_headimgurl"`
+	KfWx             string `json:"kf_wx"`              // 已绑定的微信号
+	InviteWx         string `json:"invite_wx"`          // 待绑定的微信号
+	InviteExpireTime int64  `json:"invite_expire_time"` // 邀请过期时间
+	InviteStatus     string `json:"invite_status"`      // waiting、rejected、expired
+}
+
+// checkKfAccount 客服账号格式为 账号前缀@公众号微信号，前缀最多10个字符
+func checkKfAccount(kfAccount string) error {
+	i := strings.Index(kfAccount, "@")
+	if i <= 0 || i > 10 || i == len(kfAccount)-1 {
+		return fmt.Errorf("wechat: invalid kf_account %v", kfAccount)
+	}
+	return nil
+}
+
+// AddKfAccountCtx 添加客服账号，nickname最长16个字
+func (s *Server) AddKfAccountCtx(ctx context.Context, kfAccount, nickname string) error {
+	if err := checkKfAccount(kfAccount); err != nil {
+		return err
+	}
+	return s.postCtx(ctx, WXAPIKfAccountAdd, map[string]string{"kf_account": kfAccount, "nickname": nickname}, nil)
+}
+
+// UpdateKfAccountCtx 修改客服昵称
+func (s *Server) UpdateKfAccountCtx(ctx context.Context, kfAccount, nickname string) error {
+	if err := checkKfAccount(kfAccount); err != nil {
+		return err
+	}
+	return s.postCtx(ctx, WXAPIKfAccountUpdate, map[string]string{"kf_account": kfAccount, "nickname": nickname}, nil)
+}
+
+// DelKfAccountCtx 删除客服账号
+func (s *Server) DelKfAccountCtx(ctx context.Context, kfAccount string) error {
+	if err := checkKfAccount(kfAccount); err != nil {
+		return err
+	}
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return err
+	}
+	return s.getCtx(ctx, fmt.Sprintf(WXAPIKfAccountDel, token, url.QueryEscape(kfAccount)), nil)
+}
+
+// UploadKfHeadImgCtx 上传客服头像，须为jpg格式，推荐640*640
+func (s *Server) UploadKfHeadImgCtx(ctx context.Context, kfAccount, filename string, r io.Reader) error {
+	if err := checkKfAccount(kfAccount); err != nil {
+		return err
+	}
+	if r == nil {
+		return errors.New("wechat: nil head image reader")
+	}
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return err
+	}
+	uri := fmt.Sprintf(WXAPIKfAccountHeadImg, token, url.QueryEscape(kfAccount))
+	return s.uploadCtx(ctx, uri, []util.MultipartFormField{fileField("media", filename, r)}, nil)
+}
+
+// GetKfListCtx 获取全部客服账号
+func (s *Server) GetKfListCtx(ctx context.Context) ([]MpKfAccount, error) {
+	ret := new(struct {
+		WxErr
+		KfList []MpKfAccount `json:"kf_list"`
+	})
+	if err := s.getCtx(ctx, WXAPIKfList, ret); err != nil {
+		return nil, err
+	}
+	return ret.KfList, nil
+}
+
+// CreateKfSessionCtx 为用户创建客服会话，用户须已关注且48小时内与公众号有过互动
+func (s *Server) CreateKfSessionCtx(ctx context.Context, kfAccount, openId string) error {
+	return s.postCtx(ctx, WXAPIKfSessionCreate, map[string]string{"kf_account": kfAccount, "openid": openId}, nil)
+}
+
+// CloseKfSessionCtx 关闭客服会话
+func (s *Server) CloseKfSessionCtx(ctx context.Context, kfAccount, openId string) error {
+	return s.postCtx(ctx, WXAPIKfSessionClose, map[string]string{"kf_account": kfAccount, "openid": openId}, nil)
+}
+
+// MpKfSession 客服会话
+type MpKfSession struct {
+	KfAccount  string `json:"kf_account"`
+	OpenId     string `json:"openid"`
+	CreateTime int64  `json:"createtime"`
+}
+
+// GetKfSessionCtx 获取用户的会话状态，未接入时KfAccount为空
+func (s *Server) GetKfSessionCtx(ctx context.Context, openId string) (*MpKfSession, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(struct {
+		WxErr
+		MpKfSession
+	})
+	if err = s.getCtx(ctx, fmt.Sprintf(WXAPIKfSessionGet, token, url.QueryEscape(openId)), ret); err != nil {
+		return nil, err
+	}
+	ret.OpenId = openId
+	return &ret.MpKfSession, nil
+}
+
+// GetKfSessionListCtx 获取客服正在接待的会话列表
+func (s *Server) GetKfSessionListCtx(ctx context.Context, kfAccount string) ([]MpKfSession, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(struct {
+		WxErr
+		SessionList []MpKfSession `json:"sessionlist"`
+	})
+	if err = s.getCtx(ctx, fmt.Sprintf(WXAPIKfSessionList, token, url.QueryEscape(kfAccount)), ret); err != nil {
+		return nil, err
+	}
+	for i := range ret.SessionList {
+		ret.SessionList[i].KfAccount = kfAccount
+	}
+	return ret.SessionList, nil
+}
+
+// MpKfWaitCase 等待接入的会话
+type MpKfWaitCase struct {
+	OpenId     string `json:"openid"`
+	LatestTime int64  `json:"latest_time"` // 用户最后一条消息的时间
+}
+
+// GetKfWaitCaseCtx 获取未接入会话列表，按用户等待时间排序，最多返回100个，count为总数
+func (s *Server) GetKfWaitCaseCtx(ctx context.Context) (count int, list []MpKfWaitCase, err error) {
+	ret := new(struct {
+		WxErr
+		Count        int            `json:"count"`
+		WaitCaseList []MpKfWaitCase `json:"waitcaselist"`
+	})
+	if err = s.getCtx(ctx, WXAPIKfSessionWaiting, ret); err != nil {
+		return
+	}
+	return ret.Count, ret.WaitCaseList, nil
+}
+
+// MpKfMsgRecord 客服聊天记录
+type MpKfMsgRecord struct {
+	Worker   string `json:"worker"` // 客服账号
+	OpenId   string `json:"openid"`
+	OperCode int    `json:"opercode"` // 2002客服发送，2003客服接收
+	Text     string `json:"text"`
+	Time     int64  `json:"time"`
+}
+
+// MpKfMsgRecords 客服聊天记录分页结果
+type MpKfMsgRecords struct {
+	WxErr
+	RecordList []MpKfMsgRecord `json:"recordlist"`
+	Number     int             `json:"number"`
+	MsgId      int64           `json:"msgid"` // 下一页的起始msgid
+}
+
+// GetKfMsgRecordsCtx 获取客服聊天记录，起止时间不能超过24小时，msgId首次传1，number为1-10000
+// 返回的Number等于请求的number时可能还有数据，以返回的MsgId继续获取下一页
+func (s *Server) GetKfMsgRecordsCtx(ctx context.Context, start, end time.Time, msgId int64, number int) (*MpKfMsgRecords, error) {
+	if !end.After(start) || end.Sub(start) > 24*time.Hour {
+		return nil, errors.New("wechat: kf msg record time range must be within 24 hours")
+	}
+	if number <= 0 || number > MpKfMsgRecordMax {
+		return nil, fmt.Errorf("wechat: number %d must be in 1-%d", number, MpKfMsgRecordMax)
+	}
+	if msgId <= 0 {
+		msgId = 1
+	}
+	req := map[string]int64{
+		"starttime": start.Unix(),
+		"endtime":   end.Unix(),
+		"msgid":     msgId,
+		"number":    int64(number),
+	}
+	ret := new(MpKfMsgRecords)
+	if err := s.postCtx(ctx, WXAPIKfMsgList, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// KfTypingCtx 下发或取消“正在输入”状态，仅在用户48小时内与公众号有过互动时有效
+// 对同一用户每分钟最多5次，超限可通过util.IsErrCode(err, 45047)判断
+func (s *Server) KfTypingCtx(ctx context.Context, openId string, typing bool) error {
+	command := "Typing"
+	if !typing {
+		command = "CancelTyping"
+	}
+	return s.postCtx(ctx, WXAPIKfTyping, map[string]string{"touser": openId, "command": command}, nil)
+}
@@ -0,0 +1,153 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WXAPIMassSendAll 群发接口
+const (
+	WXAPIMassSendAll = WXAPI + "message/mass/sendall?access_token="
+	WXAPIMassSend    = WXAPI + "message/mass/send?access_token="
+	WXAPIMassPreview = WXAPI + "message/mass/preview?access_token="
+	WXAPIMassDel     = WXAPI + "message/mass/delete?access_token="
+	WXAPIMassGet     = WXAPI + "message/mass/get?access_token="
+)
+
+// 按openid群发的用户数限制
+const (
+	MpMassMinUsers = 2
+	MpMassMaxUsers = 10000
+)
+
+// TypeMpVideo 群发视频消息
+const TypeMpVideo = "mpvideo"
+
+// MpMassMsg 群发消息，MsgType为mpnews、text、image、voice、mpvideo、wxcard
+type MpMassMsg struct {
+	MsgType           string
+	MediaId           string // mpnews、image、voice、mpvideo使用
+	Content           string // text使用
+	CardId            string // wxcard使用
+	SendIgnoreReprint int    // 图文被判定为转载时，1-继续群发，0-停止群发
+	ClientMsgId       string // 防重入id，24小时内相同id只群发一次
+}
+
+// MpMassResult 群发结果
+type MpMassResult struct {
+	WxErr
+	MsgId     int64 `json:"msg_id"`
+	MsgDataId int64 `json:"msg_data_id"` // 图文消息的数据id，可用于获取图文分析数据
+}
+
+// payload 按消息类型生成请求体
+func (m *MpMassMsg) payload() (map[string]interface{}, error) {
+	req := map[string]interface{}{"msgtype": m.MsgType}
+	switch m.MsgType {
+	case TypeText:
+		if m.Content == "" {
+			return nil, errors.New("wechat: mass text content is empty")
+		}
+		req[m.MsgType] = map[string]string{"content": m.Content}
+	case TypeMpNews, TypeImage, TypeVoice, TypeMpVideo:
+		if m.MediaId == "" {
+			return nil, ErrEmptyMediaId
+		}
+		req[m.MsgType] = map[string]string{"media_id": m.MediaId}
+	case TypeWxCard:
+		req[m.MsgType] = map[string]string{"card_id": m.CardId}
+	default:
+		return nil, errors.New("wechat: unsupported mass msgtype " + m.MsgType)
+	}
+	if m.MsgType == TypeMpNews {
+		req["send_ignore_reprint"] = m.SendIgnoreReprint
+	}
+	if m.ClientMsgId != "" {
+		req["clientmsgid"] = m.ClientMsgId
+	}
+	return req, nil
+}
+
+// SendMassToTagCtx 按标签群发消息
+func (s *Server) SendMassToTagCtx(ctx context.Context, tagId int, msg *MpMassMsg) (*MpMassResult, error) {
+	return s.sendMassAll(ctx, map[string]interface{}{"is_to_all": false, "tag_id": tagId}, msg)
+}
+
+// SendMassToAllCtx 群发消息给全部用户
+func (s *Server) SendMassToAllCtx(ctx context.Context, msg *MpMassMsg) (*MpMassResult, error) {
+	return s.sendMassAll(ctx, map[string]interface{}{"is_to_all": true}, msg)
+}
+
+// SendMassToUsersCtx 按openid列表群发消息，openid数量需在2-10000之间
+func (s *Server) SendMassToUsersCtx(ctx context.Context, openIds []string, msg *MpMassMsg) (*MpMassResult, error) {
+	if n := len(openIds); n < MpMassMinUsers || n > MpMassMaxUsers {
+		return nil, fmt.Errorf("wechat: mass send to %d users, must be %d-%d", n, MpMassMinUsers, MpMassMaxUsers)
+	}
+	req, err := msg.payload()
+	if err != nil {
+		return nil, err
+	}
+	req["touser"] = openIds
+	ret := new(MpMassResult)
+	if err = s.postCtx(ctx, WXAPIMassSend, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *Server) sendMassAll(ctx context.Context, filter map[string]interface{}, msg *MpMassMsg) (*MpMassResult, error) {
+	req, err := msg.payload()
+	if err != nil {
+		return nil, err
+	}
+	req["filter"] = filter
+	ret := new(MpMassResult)
+	if err = s.postCtx(ctx, WXAPIMassSendAll, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PreviewMassCtx 预览群发消息，发送给指定openid
+func (s *Server) PreviewMassCtx(ctx context.Context, openId string, msg *MpMassMsg) error {
+	return s.previewMass(ctx, "touser", openId, msg)
+}
+
+// PreviewMassByWxNameCtx 预览群发消息，发送给指定微信号，优先级高于openid
+func (s *Server) PreviewMassByWxNameCtx(ctx context.Context, wxName string, msg *MpMassMsg) error {
+	return s.previewMass(ctx, "towxname", wxName, msg)
+}
+
+func (s *Server) previewMass(ctx context.Context, key, to string, msg *MpMassMsg) error {
+	req, err := msg.payload()
+	if err != nil {
+		return err
+	}
+	req[key] = to
+	return s.postCtx(ctx, WXAPIMassPreview, req, nil)
+}
+
+// DelMassCtx 删除群发消息，仅能删除图文和视频消息，articleIdx为图文中第几篇，从1开始，为0时删除全部
+func (s *Server) DelMassCtx(ctx context.Context, msgId int64, articleIdx int) error {
+	req := map[string]interface{}{"msg_id": msgId}
+	if articleIdx > 0 {
+		req["article_idx"] = articleIdx
+	}
+	return s.postCtx(ctx, WXAPIMassDel, req, nil)
+}
+
+// MassStatusSuccess 群发成功状态
+const MassStatusSuccess = "SEND_SUCCESS"
+
+// MassStatusCtx 查询群发消息发送状态，返回SEND_SUCCESS、SENDING、SEND_FAIL、DELETE
+func (s *Server) MassStatusCtx(ctx context.Context, msgId int64) (string, error) {
+	ret := new(struct {
+		WxErr
+		MsgStatus string `json:"msg_status"`
+	})
+	if err := s.postCtx(ctx, WXAPIMassGet, map[string]interface{}{"msg_id": msgId}, ret); err != nil {
+		return "", err
+	}
+	return ret.MsgStatus, nil
+}
@@ -0,0 +1,213 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXAPIMaterialAdd 永久素材接口
+const (
+	WXAPIMaterialAdd   = WXAPI + "material/add_material?access_token=%s&type=%s"
+	WXAPIMaterialGet   = WXAPI + "material/get_material?access_token="
+	WXAPIMaterialDel   = WXAPI + "material/del_material?access_token="
+	WXAPIMaterialCount = WXAPI + "material/get_materialcount?access_token="
+)
+
+// TypeThumb 缩略图素材
+const TypeThumb = "thumb"
+
+// MpMaterial 永久素材上传回复体，Url仅图片素材返回，可用于图文消息
+type MpMaterial struct {
+	WxErr
+	MediaId string `json:"media_id"`
+	Url     string `json:"url"`
+}
+
+// AddMaterialImageCtx 上传永久图片素材，返回media_id及图片url
+func (s *Server) AddMaterialImageCtx(ctx context.Context, filename string, r io.Reader) (*MpMaterial, error) {
+	return s.addMaterial(ctx, TypeImage, filename, r)
+}
+
+// AddMaterialVoiceCtx 上传永久语音素材
+func (s *Server) AddMaterialVoiceCtx(ctx context.Context, filename string, r io.Reader) (*MpMaterial, error) {
+	return s.addMaterial(ctx, TypeVoice, filename, r)
+}
+
+// AddMaterialThumbCtx 上传永久缩略图素材
+func (s *Server) AddMaterialThumbCtx(ctx context.Context, filename string, r io.Reader) (*MpMaterial, error) {
+	return s.addMaterial(ctx, TypeThumb, filename, r)
+}
+
+// AddMaterialVideoCtx 上传永久视频素材，title、introduction作为description表单字段一并提交
+func (s *Server) AddMaterialVideoCtx(ctx context.Context, filename string, r io.Reader, title, introduction string) (*MpMaterial, error) {
+	desc, err := json.Marshal(map[string]string{"title": title, "introduction": introduction})
+	if err != nil {
+		return nil, err
+	}
+	return s.addMaterial(ctx, TypeVideo, filename, r, util.MultipartFormField{Fieldname: "description", Value: desc})
+}
+
+// addMaterial 上传永久素材，extra为附加的表单字段
+func (s *Server) addMaterial(ctx context.Context, mediaType, filename string, r io.Reader, extra ...util.MultipartFormField) (*MpMaterial, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fields := append([]util.MultipartFormField{fileField("media", filename, r)}, extra...)
+	m := new(MpMaterial)
+	if err = s.uploadCtx(ctx, fmt.Sprintf(WXAPIMaterialAdd, token, mediaType), fields, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MpMaterialContent 永久素材内容，图片、语音、缩略图素材为文件内容Data，
+// 视频素材为Title、Description、DownUrl，图文素材为NewsItem原始json
+type MpMaterialContent struct {
+	Data []byte `json:"-"`
+
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	DownUrl     string          `json:"down_url"`
+	NewsItem    json.RawMessage `json:"news_item"`
+}
+
+// GetMaterialCtx 获取永久素材
+func (s *Server) GetMaterialCtx(ctx context.Context, mediaId string) (*MpMaterialContent, error) {
+	uri, err := s.withToken(ctx, WXAPIMaterialGet)
+	if err != nil {
+		return nil, err
+	}
+	b, err := util.PostJsonCtx(ctx, uri, map[string]string{"media_id": mediaId})
+	if err != nil {
+		return nil, err
+	}
+	mc := new(MpMaterialContent)
+	if t := bytes.TrimSpace(b); len(t) == 0 || t[0] != '{' || !json.Valid(t) {
+		mc.Data = b
+		return mc, nil
+	}
+	if err = util.CheckErrCode(b); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, mc); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// DelMaterialCtx 删除永久素材
+func (s *Server) DelMaterialCtx(ctx context.Context, mediaId string) error {
+	return s.postCtx(ctx, WXAPIMaterialDel, map[string]string{"media_id": mediaId}, nil)
+}
+
+// MpMaterialCount 永久素材总数
+type MpMaterialCount struct {
+	WxErr
+	VoiceCount int `json:"voice_count"`
+	VideoCount int `json:"video_count"`
+	ImageCount int `json:"image_count"`
+	NewsCount  int `json:"news_count"`
+}
+
+// GetMaterialCountCtx 获取永久素材总数
+func (s *Server) GetMaterialCountCtx(ctx context.Context) (*MpMaterialCount, error) {
+	mc := new(MpMaterialCount)
+	if err := s.getCtx(ctx, WXAPIMaterialCount, mc); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// WXAPIMaterialBatchGet 永久素材列表接口
+const WXAPIMaterialBatchGet = WXAPI + "material/batchget_material?access_token="
+
+// MpMaterialBatchMax 永久素材列表每页最多20个
+const MpMaterialBatchMax = 20
+
+// MpMaterialItem 永久素材列表项，图片、语音、视频素材为Name、Url，图文素材为Content.NewsItem
+type MpMaterialItem struct {
+	MediaId    string `json:"media_id"`
+	Name       string `json:"name"`
+	Url        string `json:"url"`
+	UpdateTime int64  `json:"update_time"`
+	Content    *struct {
+		NewsItem   json.RawMessage `json:"news_item"`
+		CreateTime int64           `json:"create_time"`
+		UpdateTime int64           `json:"update_time"`
+	} `json:"content,omitempty"`
+}
+
+// MpMaterialList 永久素材列表
+type MpMaterialList struct {
+	WxErr
+	TotalCount int              `json:"total_count"`
+	ItemCount  int              `json:"item_count"`
+	Item       []MpMaterialItem `json:"item"`
+}
+
+// BatchGetMaterialCtx 分页获取永久素材列表，mediaType为image、video、voice、news，count最多20
+func (s *Server) BatchGetMaterialCtx(ctx context.Context, mediaType string, offset, count int) (*MpMaterialList, error) {
+	if count <= 0 || count > MpMaterialBatchMax {
+		count = MpMaterialBatchMax
+	}
+	req := map[string]interface{}{"type": mediaType, "offset": offset, "count": count}
+	ml := new(MpMaterialList)
+	if err := s.postCtx(ctx, WXAPIMaterialBatchGet, req, ml); err != nil {
+		return nil, err
+	}
+	return ml, nil
+}
+
+// MpMaterialIterator 永久素材列表迭代器，按offset翻页直到total_count
+type MpMaterialIterator struct {
+	s         *Server
+	mediaType string
+	offset    int
+	page      *MpMaterialList
+	err       error
+	done      bool
+}
+
+// MpMaterialIterator 创建永久素材列表迭代器
+func (s *Server) MpMaterialIterator(mediaType string) *MpMaterialIterator {
+	return &MpMaterialIterator{s: s, mediaType: mediaType}
+}
+
+// Next 获取下一页，没有更多数据、出错或ctx取消时返回false
+func (it *MpMaterialIterator) Next(ctx context.Context) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if it.err = ctx.Err(); it.err != nil {
+		return false
+	}
+	it.page, it.err = it.s.BatchGetMaterialCtx(ctx, it.mediaType, it.offset, MpMaterialBatchMax)
+	if it.err != nil {
+		return false
+	}
+	if len(it.page.Item) == 0 {
+		it.done = true
+		return false
+	}
+	it.offset += len(it.page.Item)
+	if it.offset >= it.page.TotalCount {
+		it.done = true
+	}
+	return true
+}
+
+// Page 返回当前页
+func (it *MpMaterialIterator) Page() *MpMaterialList {
+	return it.page
+}
+
+// Err 返回迭代过程中的错误
+func (it *MpMaterialIterator) Err() error {
+	return it.err
+}
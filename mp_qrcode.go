@@ -0,0 +1,93 @@
+package wechat
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXAPIQrcodeCreate 带参数二维码接口
+const (
+	WXAPIQrcodeCreate = WXAPI + "qrcode/create?access_token="
+	WXAPIQrcodeShow   = "https://mp.weixin.qq.com/cgi-bin/showqrcode?ticket="
+)
+
+// 二维码类型
+const (
+	QrScene         = "QR_SCENE"           // 临时整型参数
+	QrStrScene      = "QR_STR_SCENE"       // 临时字符串参数
+	QrLimitScene    = "QR_LIMIT_SCENE"     // 永久整型参数，scene_id取值1-100000
+	QrLimitStrScene = "QR_LIMIT_STR_SCENE" // 永久字符串参数，长度1-64
+)
+
+// QrcodeMaxExpire 临时二维码最长有效期30天
+const QrcodeMaxExpire = 30 * 24 * time.Hour
+
+// MpQrTicket 二维码ticket，可通过ticket换取二维码图片，或直接用Url自行生成二维码
+type MpQrTicket struct {
+	WxErr
+	Ticket        string `json:"ticket"`
+	ExpireSeconds int    `json:"expire_seconds"` // 永久二维码为0
+	Url           string `json:"url"`
+}
+
+type qrScene struct {
+	SceneId  int    `json:"scene_id,omitempty"`
+	SceneStr string `json:"scene_str,omitempty"`
+}
+
+type qrCreate struct {
+	ExpireSeconds int    `json:"expire_seconds,omitempty"`
+	ActionName    string `json:"action_name"`
+	ActionInfo    struct {
+		Scene qrScene `json:"scene"`
+	} `json:"action_info"`
+}
+
+// createQrcode 创建二维码ticket
+func (s *Server) createQrcode(ctx context.Context, action string, scene qrScene, expire time.Duration) (*MpQrTicket, error) {
+	if expire > QrcodeMaxExpire {
+		expire = QrcodeMaxExpire
+	}
+	req := qrCreate{ExpireSeconds: int(expire / time.Second), ActionName: action}
+	req.ActionInfo.Scene = scene
+	t := new(MpQrTicket)
+	if err := s.postCtx(ctx, WXAPIQrcodeCreate, req, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// CreateTempQrcodeCtx 创建临时整型参数二维码，expire最长30天，为0时微信默认30秒
+func (s *Server) CreateTempQrcodeCtx(ctx context.Context, sceneId int, expire time.Duration) (*MpQrTicket, error) {
+	return s.createQrcode(ctx, QrScene, qrScene{SceneId: sceneId}, expire)
+}
+
+// CreateTempQrcodeStrCtx 创建临时字符串参数二维码
+func (s *Server) CreateTempQrcodeStrCtx(ctx context.Context, sceneStr string, expire time.Duration) (*MpQrTicket, error) {
+	return s.createQrcode(ctx, QrStrScene, qrScene{SceneStr: sceneStr}, expire)
+}
+
+// CreateQrcodeCtx 创建永久整型参数二维码
+func (s *Server) CreateQrcodeCtx(ctx context.Context, sceneId int) (*MpQrTicket, error) {
+	return s.createQrcode(ctx, QrLimitScene, qrScene{SceneId: sceneId}, 0)
+}
+
+// CreateQrcodeStrCtx 创建永久字符串参数二维码
+func (s *Server) CreateQrcodeStrCtx(ctx context.Context, sceneStr string) (*MpQrTicket, error) {
+	return s.createQrcode(ctx, QrLimitStrScene, qrScene{SceneStr: sceneStr}, 0)
+}
+
+// QrcodeImageCtx 通过ticket换取二维码图片，响应不是图片(如json错误、html页面)时返回error
+func QrcodeImageCtx(ctx context.Context, ticket string) ([]byte, error) {
+	b, _, err := util.GetBodyWithResponseCtx(ctx, WXAPIQrcodeShow+url.QueryEscape(ticket), util.WithMediaType("image/"))
+	return b, err
+}
+
+// QrcodeImageToWriterCtx 通过ticket换取二维码图片并写入w，返回写入的字节数
+func QrcodeImageToWriterCtx(ctx context.Context, w io.Writer, ticket string) (int64, error) {
+	return util.GetToWriterCtx(ctx, w, WXAPIQrcodeShow+url.QueryEscape(ticket), util.WithMediaType("image/"))
+}
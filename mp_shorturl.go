@@ -0,0 +1,21 @@
+package wechat
+
+import "context"
+
+// WXAPIShortUrl 长链接转短链接接口，微信已于2021年停止新的调用，小程序可改用GenerateUrlLink
+const (
+	WXAPIShortUrl = WXAPI + "shorturl?access_token="
+)
+
+// ShortUrlCtx 长链接转短链接
+func (s *Server) ShortUrlCtx(ctx context.Context, longUrl string) (string, error) {
+	req := map[string]string{"action": "long2short", "long_url": longUrl}
+	ret := new(struct {
+		WxErr
+		ShortUrl string `json:"short_url"`
+	})
+	if err := s.postCtx(ctx, WXAPIShortUrl, req, ret); err != nil {
+		return "", err
+	}
+	return ret.ShortUrl, nil
+}
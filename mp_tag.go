@@ -0,0 +1,113 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXAPITagCreate 公众号用户标签接口
+const (
+	WXAPITagCreate = WXAPI + "tags/create?access_token="
+	WXAPITagGet    = WXAPI + "tags/get?access_token="
+	WXAPITagUpdate = WXAPI + "tags/update?access_token="
+	WXAPITagDelete = WXAPI + "tags/delete?access_token="
+)
+
+// 公众号标签限制
+const (
+	MpTagMax        = 100 // 最多创建100个标签
+	MpTagNameMaxLen = 30  // 标签名最长30个字符
+)
+
+// mpTagErrLimit 标签数量超过上限的错误码
+const mpTagErrLimit = 45056
+
+// ErrMpTagLimit 标签数量已达上限
+var ErrMpTagLimit = errors.New("wechat: mp tag limit exceeded")
+
+// MpTag 公众号用户标签
+type MpTag struct {
+	Id    int    `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count,omitempty"` // 此标签下粉丝数
+}
+
+// MpTagListCtx 获取已创建的标签
+func (s *Server) MpTagListCtx(ctx context.Context) ([]MpTag, error) {
+	ret := new(struct {
+		WxErr
+		Tags []MpTag `json:"tags"`
+	})
+	if err := s.getCtx(ctx, WXAPITagGet, ret); err != nil {
+		return nil, err
+	}
+	return ret.Tags, nil
+}
+
+// MpTagCreateCtx 创建标签，已有100个标签时微信返回45056，映射为ErrMpTagLimit
+func (s *Server) MpTagCreateCtx(ctx context.Context, name string) (*MpTag, error) {
+	if err := checkMpTagName(name); err != nil {
+		return nil, err
+	}
+	ret := new(struct {
+		WxErr
+		Tag MpTag `json:"tag"`
+	})
+	if err := s.postCtx(ctx, WXAPITagCreate, map[string]MpTag{"tag": {Name: name}}, ret); err != nil {
+		if util.IsErrCode(err, mpTagErrLimit) {
+			return nil, ErrMpTagLimit
+		}
+		return nil, err
+	}
+	return &ret.Tag, nil
+}
+
+// MpTagUpdateCtx 修改标签名
+func (s *Server) MpTagUpdateCtx(ctx context.Context, id int, name string) error {
+	if err := checkMpTagName(name); err != nil {
+		return err
+	}
+	return s.postCtx(ctx, WXAPITagUpdate, map[string]MpTag{"tag": {Id: id, Name: name}}, nil)
+}
+
+// MpTagDeleteCtx 删除标签，粉丝数超过10万的标签无法直接删除
+func (s *Server) MpTagDeleteCtx(ctx context.Context, id int) error {
+	return s.postCtx(ctx, WXAPITagDelete, map[string]MpTag{"tag": {Id: id}}, nil)
+}
+
+func checkMpTagName(name string) error {
+	if n := utf8.RuneCountInString(name); n == 0 || n > MpTagNameMaxLen {
+		return fmt.Errorf("wechat: mp tag name length %d, must be 1-%d", n, MpTagNameMaxLen)
+	}
+	return nil
+}
+
+// WXAPITagBatchTagging 批量为用户打标签接口
+const (
+	WXAPITagBatchTagging   = WXAPI + "tags/members/batchtagging?access_token="
+	WXAPITagBatchUntagging = WXAPI + "tags/members/batchuntagging?access_token="
+)
+
+// MpTagBatchMax 批量打标签每次最多50个openid
+const MpTagBatchMax = 50
+
+// MpTagBatchTagCtx 批量为用户打标签，超过50个时自动分批，某批失败时停止并返回*BatchError
+func (s *Server) MpTagBatchTagCtx(ctx context.Context, tagId int, openIds []string) error {
+	return s.mpTagBatch(ctx, WXAPITagBatchTagging, tagId, openIds)
+}
+
+// MpTagBatchUntagCtx 批量为用户取消标签，超过50个时自动分批，某批失败时停止并返回*BatchError
+func (s *Server) MpTagBatchUntagCtx(ctx context.Context, tagId int, openIds []string) error {
+	return s.mpTagBatch(ctx, WXAPITagBatchUntagging, tagId, openIds)
+}
+
+func (s *Server) mpTagBatch(ctx context.Context, uri string, tagId int, openIds []string) error {
+	return batch(len(openIds), MpTagBatchMax, func(start, end int) error {
+		req := map[string]interface{}{"openid_list": openIds[start:end], "tagid": tagId}
+		return s.postCtx(ctx, uri, req, nil)
+	})
+}
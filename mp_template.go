@@ -1,8 +1,8 @@
 package wechat
 
 import (
+	"context"
 	"errors"
-	"fmt"
 
 	"github.com/esap/wechat/util"
 )
@@ -13,6 +13,9 @@ const (
 	MPTemplateAdd     = WXAPI + "template/api_add_template?access_token="
 	MPTemplateDel     = WXAPI + "template/del_private_template?access_token="
 	MPTemplateSendMsg = WXAPI + "message/template/send?access_token="
+
+	MPTemplateSetIndustry = WXAPI + "template/api_set_industry?access_token="
+	MPTemplateGetIndustry = WXAPI + "template/get_industry?access_token="
 )
 
 // MpTemplate 模板信息
@@ -27,51 +30,80 @@ type MpTemplate struct {
 
 // AddTemplate 获取模板
 func (s *Server) AddTemplate(IdShort string) (id string, err error) {
-	form := map[string]interface{}{"template_id_short": IdShort}
-
-	ret := make(map[string]interface{})
-	err = util.PostJsonPtr(MPTemplateAdd+s.GetAccessToken(), form, ret)
-	if err != nil {
-		return
-	}
-
-	if fmt.Sprint(ret["errcode"]) != "0" {
-		return "", errors.New(fmt.Sprint(ret["errcode"]))
-	}
-
-	return ret["template_id"].(string), nil
+	return s.AddTemplateCtx(context.Background(), IdShort)
 }
 
 // DelTemplate 删除模板
 func (s *Server) DelTemplate(id string) (err error) {
-	form := map[string]interface{}{"template_id": id}
+	return s.DelTemplateCtx(context.Background(), id)
+}
 
-	ret := make(map[string]interface{})
-	err = util.PostJsonPtr(MPTemplateDel+s.GetAccessToken(), form, ret)
-	if err != nil {
-		return
-	}
+// GetAllTemplate 获取模板
+func (s *Server) GetAllTemplate() (templist []MpTemplate, err error) {
+	return s.GetAllTemplateCtx(context.Background())
+}
 
-	if fmt.Sprint(ret["errcode"]) != "0" {
-		return errors.New(fmt.Sprint(ret["errcode"]))
+// AddTemplateCtx 从模板库添加模板，返回template_id，keywordNames为选用的关键词(新版类目模板必填)
+func (s *Server) AddTemplateCtx(ctx context.Context, idShort string, keywordNames ...string) (string, error) {
+	if idShort == "" {
+		return "", errors.New("wechat: empty template_id_short")
 	}
+	req := struct {
+		TemplateIdShort string   `json:"template_id_short"`
+		KeywordNameList []string `json:"keyword_name_list,omitempty"`
+	}{idShort, keywordNames}
+	ret := new(struct {
+		WxErr
+		TemplateId string `json:"template_id"`
+	})
+	if err := s.postCtx(ctx, MPTemplateAdd, &req, ret); err != nil {
+		return "", err
+	}
+	return ret.TemplateId, nil
+}
 
-	return
+// DelTemplateCtx 删除模板
+func (s *Server) DelTemplateCtx(ctx context.Context, templateId string) error {
+	return s.postCtx(ctx, MPTemplateDel, map[string]string{"template_id": templateId}, nil)
 }
 
-// GetAllTemplate 获取模板
-func (s *Server) GetAllTemplate() (templist []MpTemplate, err error) {
-	ret := make(map[string]interface{})
-	err = util.GetJson(MPTemplateGetAll+s.GetAccessToken(), ret)
-	if err != nil {
-		return
+// GetAllTemplateCtx 获取已添加的全部模板
+func (s *Server) GetAllTemplateCtx(ctx context.Context) ([]MpTemplate, error) {
+	ret := new(struct {
+		WxErr
+		TemplateList []MpTemplate `json:"template_list"`
+	})
+	if err := s.getCtx(ctx, MPTemplateGetAll, ret); err != nil {
+		return nil, err
 	}
+	return ret.TemplateList, nil
+}
 
-	if fmt.Sprint(ret["errcode"]) != "0" {
-		return nil, errors.New(fmt.Sprint(ret["errcode"]))
-	}
+// MpIndustryClass 行业类别
+type MpIndustryClass struct {
+	FirstClass  string `json:"first_class"`
+	SecondClass string `json:"second_class"`
+}
+
+// MpIndustry 账号设置的所属行业
+type MpIndustry struct {
+	WxErr
+	PrimaryIndustry   MpIndustryClass `json:"primary_industry"`
+	SecondaryIndustry MpIndustryClass `json:"secondary_industry"`
+}
 
-	return ret["template_id"].([]MpTemplate), nil
+// SetIndustryCtx 设置所属行业，id1为主营行业，id2为副营行业，行业代码见模板消息文档，每月可修改1次
+func (s *Server) SetIndustryCtx(ctx context.Context, id1, id2 string) error {
+	return s.postCtx(ctx, MPTemplateSetIndustry, map[string]string{"industry_id1": id1, "industry_id2": id2}, nil)
+}
+
+// GetIndustryCtx 获取设置的行业信息
+func (s *Server) GetIndustryCtx(ctx context.Context) (*MpIndustry, error) {
+	ret := new(MpIndustry)
+	if err := s.getCtx(ctx, MPTemplateGetIndustry, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
 }
 
 // SendTemplate 发送模板消息，data通常是map[string]struct{value string,color string}
@@ -98,3 +130,37 @@ func (s *Server) SendTemplate(to, id, url, appid, pagepath string, data interfac
 
 	return ret
 }
+
+// MpTemplateValue 模板消息字段值
+type MpTemplateValue struct {
+	Value string `json:"value"`
+	Color string `json:"color,omitempty"`
+}
+
+// MpTemplateMiniProgram 模板消息跳转的小程序
+type MpTemplateMiniProgram struct {
+	AppId    string `json:"appid"`
+	PagePath string `json:"pagepath,omitempty"`
+}
+
+// MpTemplateMsg 模板消息，同时设置Url与MiniProgram时优先跳转小程序
+type MpTemplateMsg struct {
+	ToUser      string                     `json:"touser"`
+	TemplateId  string                     `json:"template_id"`
+	Url         string                     `json:"url,omitempty"`
+	MiniProgram *MpTemplateMiniProgram     `json:"miniprogram,omitempty"`
+	Data        map[string]MpTemplateValue `json:"data"`
+	ClientMsgId string                     `json:"client_msg_id,omitempty"` // 防重入id
+}
+
+// SendTemplateCtx 发送模板消息，返回msgid，用户未关注等错误可通过util.IsErrCode(err, 43004)判断
+func (s *Server) SendTemplateCtx(ctx context.Context, msg *MpTemplateMsg) (msgId int64, err error) {
+	ret := new(struct {
+		WxErr
+		MsgId int64 `json:"msgid"`
+	})
+	if err = s.postCtx(ctx, MPTemplateSendMsg, msg, ret); err != nil {
+		return
+	}
+	return ret.MsgId, nil
+}
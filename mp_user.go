@@ -1,7 +1,10 @@
 package wechat
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"unicode/utf8"
 
 	"github.com/esap/wechat/util"
 )
@@ -36,6 +39,10 @@ type (
 		Remark        string
 		GroupId       int
 		TagIdList     []int `json:"tagid_list"`
+
+		SubscribeScene string `json:"subscribe_scene"` // 关注渠道，如ADD_SCENE_QR_CODE
+		QrScene        int    `json:"qr_scene"`
+		QrSceneStr     string `json:"qr_scene_str"`
 	}
 
 	// MpUser 服务号用户
@@ -46,7 +53,13 @@ type (
 		Data  struct {
 			OpenId []string
 		}
-		NextOpenId string
+		NextOpenId string `json:"next_openid"`
+	}
+
+	// MpUserInfoReq 批量获取用户信息的请求项，Lang为空时使用zh_CN
+	MpUserInfoReq struct {
+		OpenId string `json:"openid"`
+		Lang   string `json:"lang,omitempty"`
 	}
 
 	// MpUserListReq 公众号用户请求
@@ -73,7 +86,7 @@ func (s *Server) BatchGetAll() (ui []MpUserInfo, err error) {
 		}
 
 		ui2, err2 := s.BatchGet(ul[i*100 : end])
-		if err != nil {
+		if err2 != nil {
 			err = err2
 			return
 		}
@@ -142,3 +155,121 @@ func (s *Server) GetMpUserInfo(openid string, lang ...string) (user *MpUserInfo,
 	}
 	return
 }
+
+// GetMpUserInfoCtx 获取用户详情，lang为空时使用zh_CN
+// 用户未关注(Subscribe为0)时微信仅返回openid、unionid，其余字段为零值
+func (s *Server) GetMpUserInfoCtx(ctx context.Context, openid, lang string) (*MpUserInfo, error) {
+	if lang == "" {
+		lang = "zh_CN"
+	}
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(struct {
+		WxErr
+		MpUserInfo
+	})
+	if err = s.getCtx(ctx, fmt.Sprintf(MPUserInfo, token, url.QueryEscape(openid), lang), ret); err != nil {
+		return nil, err
+	}
+	return &ret.MpUserInfo, nil
+}
+
+// MpUserBatchMax 批量获取用户信息每次最多100个openid
+const MpUserBatchMax = 100
+
+// BatchGetMpUserInfoCtx 批量获取用户信息，超过100个时自动分批请求，结果顺序与reqs一致
+// 某批失败时返回已获取的结果及错误
+func (s *Server) BatchGetMpUserInfoCtx(ctx context.Context, reqs []MpUserInfoReq) ([]MpUserInfo, error) {
+	ui := make([]MpUserInfo, 0, len(reqs))
+	for i := 0; i < len(reqs); i += MpUserBatchMax {
+		end := util.Min(i+MpUserBatchMax, len(reqs))
+		ml := new(MpUserInfoList)
+		if err := s.postCtx(ctx, MPUserBatchGet, MpUserListReq{reqs[i:end]}, ml); err != nil {
+			return ui, err
+		}
+		ui = append(ui, ml.MpUserInfoList...)
+	}
+	return ui, nil
+}
+
+// GetMpUserListCtx 获取一页关注者openid，每页最多10000个，nextOpenId为空时从头开始
+func (s *Server) GetMpUserListCtx(ctx context.Context, nextOpenId string) (*MpUser, error) {
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mpuser := new(MpUser)
+	if err = s.getCtx(ctx, fmt.Sprintf(MPUserGetList, token, url.QueryEscape(nextOpenId)), mpuser); err != nil {
+		return nil, err
+	}
+	return mpuser, nil
+}
+
+// MpUserIterator 关注者列表迭代器，自动按next_openid翻页
+//
+//	it := s.MpUserIterator()
+//	for it.Next(ctx) {
+//		page := it.Page()
+//	}
+//	if err := it.Err(); err != nil {}
+type MpUserIterator struct {
+	s    *Server
+	next string
+	page *MpUser
+	err  error
+	done bool
+}
+
+// MpUserIterator 创建关注者列表迭代器
+func (s *Server) MpUserIterator() *MpUserIterator {
+	return &MpUserIterator{s: s}
+}
+
+// Next 获取下一页，没有更多数据、出错或ctx取消时返回false
+func (it *MpUserIterator) Next(ctx context.Context) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if it.err = ctx.Err(); it.err != nil {
+		return false
+	}
+	it.page, it.err = it.s.GetMpUserListCtx(ctx, it.next)
+	if it.err != nil {
+		return false
+	}
+	if it.page.Count == 0 {
+		it.done = true
+		return false
+	}
+	it.next = it.page.NextOpenId
+	if it.next == "" {
+		it.done = true
+	}
+	return true
+}
+
+// Page 返回当前页，Total为关注者总数，Count为本页数量
+func (it *MpUserIterator) Page() *MpUser {
+	return it.page
+}
+
+// Err 返回迭代过程中的错误
+func (it *MpUserIterator) Err() error {
+	return it.err
+}
+
+// MPUserUpdateRemark 设置用户备注名接口
+const MPUserUpdateRemark = WXAPI + "user/info/updateremark?access_token="
+
+// MpUserRemarkMaxLen 备注名最长30个字符
+const MpUserRemarkMaxLen = 30
+
+// SetMpUserRemarkCtx 设置用户备注名
+func (s *Server) SetMpUserRemarkCtx(ctx context.Context, openid, remark string) error {
+	if n := utf8.RuneCountInString(remark); n > MpUserRemarkMaxLen {
+		return fmt.Errorf("wechat: remark length %d, max %d", n, MpUserRemarkMaxLen)
+	}
+	return s.postCtx(ctx, MPUserUpdateRemark, map[string]string{"openid": openid, "remark": remark}, nil)
+}
@@ -0,0 +1,116 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+
+	"github.com/esap/wechat/util"
+)
+
+// ErrMsgSignature 消息签名msg_signature校验失败
+var ErrMsgSignature = errors.New("wechat: msg_signature mismatch")
+
+// VerifySignature 校验服务器配置及明文模式回调的signature，即sha1(sort(token, timestamp, nonce))
+func VerifySignature(token, signature, timestamp, nonce string) bool {
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(util.SortSha1(token, timestamp, nonce))) == 1
+}
+
+// VerifyMsgSignature 校验安全模式及企业微信回调的msg_signature，即sha1(sort(token, timestamp, nonce, encrypt))
+func VerifyMsgSignature(token, msgSignature, timestamp, nonce, encrypt string) bool {
+	return subtle.ConstantTimeCompare([]byte(msgSignature), []byte(util.SortSha1(token, timestamp, nonce, encrypt))) == 1
+}
+
+// DecodeAESKey 解析43位的EncodingAESKey为32字节AES密钥
+func DecodeAESKey(encodingAESKey string) ([]byte, error) {
+	if len(encodingAESKey) != 43 {
+		return nil, fmt.Errorf("wechat: EncodingAESKey length %d, want 43", len(encodingAESKey))
+	}
+	return base64.StdEncoding.DecodeString(encodingAESKey + "=")
+}
+
+// decryptMsg 解密Encrypt字段，明文由16字节随机字符串、4字节msg_len(网络字节序)、msg及appId组成
+func decryptMsg(aesKey []byte, appId, encrypt string) ([]byte, error) {
+	aesMsg, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := util.AesDecrypt(aesMsg, aesKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 20 {
+		return nil, errors.New("AesKey is invalid")
+	}
+	msgLen := int(binary.BigEndian.Uint32(buf[16:20]))
+	if msgLen > len(buf)-20 {
+		return nil, errors.New("AesKey is invalid")
+	}
+	if string(buf[20+msgLen:]) != appId {
+		return nil, errors.New("AppId is invalid")
+	}
+	return buf[20 : 20+msgLen], nil
+}
+
+// DecryptCallbackMsg 解密安全模式下的回调消息，body为原始请求体(xml或json)，返回明文消息
+// 先按sha1(sort(token, timestamp, nonce, Encrypt))校验msg_signature，appId企业微信为corpid
+func DecryptCallbackMsg(token, encodingAESKey, appId, timestamp, nonce, msgSignature string, body []byte) ([]byte, error) {
+	aesKey, err := DecodeAESKey(encodingAESKey)
+	if err != nil {
+		return nil, err
+	}
+	var enc struct {
+		Encrypt string `xml:"Encrypt" json:"Encrypt"`
+	}
+	if b := bytes.TrimSpace(body); len(b) > 0 && b[0] == '{' {
+		err = json.Unmarshal(b, &enc)
+	} else {
+		err = xml.Unmarshal(b, &enc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enc.Encrypt == "" {
+		return nil, errors.New("wechat: Encrypt not found in body")
+	}
+	if !VerifyMsgSignature(token, msgSignature, timestamp, nonce, enc.Encrypt) {
+		return nil, ErrMsgSignature
+	}
+	return decryptMsg(aesKey, appId, enc.Encrypt)
+}
+
+// encryptMsg 加密回复并签名，明文由16字节随机字符串、4字节msg_len(网络字节序)、msg及appId组成
+func encryptMsg(aesKey []byte, appId, token string, msg []byte, timeStamp, nonce string) (*wxRespEnc, error) {
+	l := make([]byte, 4)
+	binary.BigEndian.PutUint32(l, uint32(len(msg)))
+	plain := bytes.Join([][]byte{[]byte(util.GetRandomString(16)), l, msg, []byte(appId)}, nil)
+	ae, err := util.AesEncrypt(plain, aesKey)
+	if err != nil {
+		return nil, err
+	}
+	encMsg := base64.StdEncoding.EncodeToString(ae)
+	return &wxRespEnc{
+		Encrypt:      CDATA(encMsg),
+		MsgSignature: CDATA(util.SortSha1(token, timeStamp, nonce, encMsg)),
+		TimeStamp:    timeStamp,
+		Nonce:        CDATA(nonce),
+	}, nil
+}
+
+// EncryptReplyMsg 加密安全模式下的被动回复，返回含Encrypt、MsgSignature、TimeStamp、Nonce的xml
+func EncryptReplyMsg(token, encodingAESKey, appId string, replyXml []byte, timestamp, nonce string) ([]byte, error) {
+	aesKey, err := DecodeAESKey(encodingAESKey)
+	if err != nil {
+		return nil, err
+	}
+	re, err := encryptMsg(aesKey, appId, token, replyXml, timestamp, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return xml.Marshal(re)
+}
@@ -0,0 +1,92 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/esap/wechat/util"
+)
+
+// 官方加解密示例参数
+const (
+	msgTestToken  = "pamtest"
+	msgTestAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	msgTestAppId  = "wxb11529c136998cb6"
+	msgTestTs     = "1409304348"
+	msgTestNonce  = "xxxxxx"
+	msgTestReply  = "<xml><ToUserName><![CDATA[oia2Tj我是中文jewbmiOUlr6X-1crbLOvLw]]></ToUserName><FromUserName><![CDATA[gh_7f083739789a]]></FromUserName><CreateTime>1407743423</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hello]]></Content></xml>"
+)
+
+func TestEncryptDecryptMsg(t *testing.T) {
+	b, err := EncryptReplyMsg(msgTestToken, msgTestAESKey, msgTestAppId, []byte(msgTestReply), msgTestTs, msgTestNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env struct {
+		Encrypt      string
+		MsgSignature string
+		TimeStamp    string
+		Nonce        string
+	}
+	if err = xml.Unmarshal(b, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.TimeStamp != msgTestTs || env.Nonce != msgTestNonce || env.Encrypt == "" {
+		t.Fatalf("unexpected envelope: %s", b)
+	}
+
+	plain, err := DecryptCallbackMsg(msgTestToken, msgTestAESKey, msgTestAppId, env.TimeStamp, env.Nonce, env.MsgSignature, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != msgTestReply {
+		t.Errorf("got %s", plain)
+	}
+
+	if _, err = DecryptCallbackMsg(msgTestToken, msgTestAESKey, msgTestAppId, env.TimeStamp, "other", env.MsgSignature, b); err != ErrMsgSignature {
+		t.Errorf("expected ErrMsgSignature, got %v", err)
+	}
+	if _, err = DecryptCallbackMsg(msgTestToken, msgTestAESKey, "wx0000000000000000", env.TimeStamp, env.Nonce, env.MsgSignature, b); err == nil {
+		t.Error("expected error with wrong appid")
+	}
+}
+
+func TestEncryptMsgPadding(t *testing.T) {
+	key, _ := DecodeAESKey(msgTestAESKey)
+	// 明文恰为32字节整数倍时需补一整块填充
+	msg := make([]byte, 32-20-len(msgTestAppId)%32+32)
+	re, err := encryptMsg(key, msgTestAppId, msgTestToken, msg, msgTestTs, msgTestNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := decryptMsg(key, msgTestAppId, string(re.Encrypt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != len(msg) {
+		t.Errorf("got %d bytes, want %d", len(plain), len(msg))
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	const sig = "76480565cbe296026c53aaacd1ad523a1ddba24f"
+	if !VerifySignature(msgTestToken, sig, msgTestTs, msgTestNonce) {
+		t.Error("valid signature rejected")
+	}
+	if VerifySignature(msgTestToken, sig, msgTestTs, "yyyyyy") {
+		t.Error("signature with wrong nonce accepted")
+	}
+	if VerifySignature(msgTestToken, "", msgTestTs, msgTestNonce) {
+		t.Error("empty signature accepted")
+	}
+}
+
+func TestVerifyMsgSignature(t *testing.T) {
+	sig := util.SortSha1(msgTestToken, msgTestTs, msgTestNonce, "encrypt")
+	if !VerifyMsgSignature(msgTestToken, sig, msgTestTs, msgTestNonce, "encrypt") {
+		t.Error("valid msg_signature rejected")
+	}
+	if VerifyMsgSignature(msgTestToken, sig, msgTestTs, msgTestNonce, "tampered") {
+		t.Error("msg_signature with tampered encrypt accepted")
+	}
+}
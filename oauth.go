@@ -1,6 +1,8 @@
 package wechat
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -10,14 +12,24 @@ import (
 // WXAPIOauth2 oauth2鉴权
 const (
 	WXAPIOauth2           = "https://open.weixin.qq.com/connect/oauth2/authorize?appid=%v&redirect_uri=%v&response_type=code&scope=snsapi_base&state=110#wechat_redirect"
+	WXAPIOauth2AuthCode   = "https://open.weixin.qq.com/connect/oauth2/authorize?appid=%v&redirect_uri=%v&response_type=code&scope=%v&state=%v#wechat_redirect"
+	WXAPIOauth2Token      = "https://api.weixin.qq.com/sns/oauth2/access_token?appid=%v&secret=%v&code=%v&grant_type=authorization_code"
+	WXAPIOauth2Refresh    = "https://api.weixin.qq.com/sns/oauth2/refresh_token?appid=%v&grant_type=refresh_token&refresh_token=%v"
+	WXAPIOauth2UserInfo   = "https://api.weixin.qq.com/sns/userinfo?access_token=%v&openid=%v&lang=%v"
 	WXAPIJscode2session   = "https://api.weixin.qq.com/sns/jscode2session?appid=%v&secret=%v&js_code=%v&grant_type=authorization_code"
 	CorpAPIJscode2session = "https://qyapi.weixin.qq.com/cgi-bin/miniprogram/jscode2session?access_token=%v&js_code=%v&grant_type=authorization_code"
 )
 
+// Oauth2 网页授权作用域
+const (
+	Oauth2ScopeBase     = "snsapi_base"     // 静默授权，仅获取openid
+	Oauth2ScopeUserInfo = "snsapi_userinfo" // 弹出授权页，可获取用户信息
+)
+
 // WxSession 兼容企业微信和服务号
 type WxSession struct {
 	WxErr
-	SessionKey string `json:"session_key"`
+	SessionKey string `json:"session_key"` // 会话密钥，仅用于服务端解密数据，不能下发给客户端
 	// corp
 	CorpId string `json:"corpid"`
 	UserId string `json:"userid"`
@@ -31,6 +43,78 @@ func GetOauth2Url(corpId, host string) string {
 	return fmt.Sprintf(WXAPIOauth2, corpId, url.QueryEscape(host))
 }
 
+// Oauth2AuthCodeURL 生成网页授权链接，redirectURI与state会被url编码，scope为空时使用snsapi_base
+func (s *Server) Oauth2AuthCodeURL(redirectURI, scope, state string) string {
+	if scope == "" {
+		scope = Oauth2ScopeBase
+	}
+	return fmt.Sprintf(WXAPIOauth2AuthCode, s.AppId, url.QueryEscape(redirectURI), scope, url.QueryEscape(state))
+}
+
+// Oauth2Token 网页授权access_token，与Server的access_token不同，不应存入TokenStore
+type Oauth2Token struct {
+	WxErr
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	OpenId       string `json:"openid"`
+	Scope        string `json:"scope"`
+	UnionId      string `json:"unionid"`
+}
+
+// Oauth2Exchange 用网页授权回调的code换取网页授权access_token及openid
+func (s *Server) Oauth2Exchange(ctx context.Context, code string) (*Oauth2Token, error) {
+	ot := new(Oauth2Token)
+	if err := util.GetJsonCtx(ctx, fmt.Sprintf(WXAPIOauth2Token, s.AppId, s.Secret, url.QueryEscape(code)), ot); err != nil {
+		return nil, err
+	}
+	if err := ot.Error(); err != nil {
+		return nil, err
+	}
+	return ot, nil
+}
+
+// Oauth2Refresh 刷新网页授权access_token，refresh_token有效期为30天
+func (s *Server) Oauth2Refresh(ctx context.Context, refreshToken string) (*Oauth2Token, error) {
+	ot := new(Oauth2Token)
+	if err := util.GetJsonCtx(ctx, fmt.Sprintf(WXAPIOauth2Refresh, s.AppId, url.QueryEscape(refreshToken)), ot); err != nil {
+		return nil, err
+	}
+	if err := ot.Error(); err != nil {
+		return nil, err
+	}
+	return ot, nil
+}
+
+// Oauth2UserInfo 网页授权用户信息
+type Oauth2UserInfo struct {
+	WxErr
+	OpenId     string   `json:"openid"`
+	Nickname   string   `json:"nickname"`
+	Sex        int      `json:"sex"` // 1-男，2-女，0-未知
+	Province   string   `json:"province"`
+	City       string   `json:"city"`
+	Country    string   `json:"country"`
+	HeadImgUrl string   `json:"headimgurl"`
+	Privilege  []string `json:"privilege"`
+	UnionId    string   `json:"unionid"`
+}
+
+// Oauth2UserInfo 拉取用户信息，需scope为snsapi_userinfo，lang可选zh_CN、zh_TW、en，为空时使用zh_CN
+func (s *Server) Oauth2UserInfo(ctx context.Context, accessToken, openId, lang string) (*Oauth2UserInfo, error) {
+	if lang == "" {
+		lang = "zh_CN"
+	}
+	ui := new(Oauth2UserInfo)
+	if err := util.GetJsonCtx(ctx, fmt.Sprintf(WXAPIOauth2UserInfo, url.QueryEscape(accessToken), url.QueryEscape(openId), lang), ui); err != nil {
+		return nil, err
+	}
+	if err := ui.Error(); err != nil {
+		return nil, err
+	}
+	return ui, nil
+}
+
 // Jscode2Session code换session
 func (s *Server) Jscode2Session(code string) (ws *WxSession, err error) {
 	url := fmt.Sprintf(WXAPIJscode2session, s.AppId, s.Secret, code)
@@ -43,6 +127,22 @@ func (s *Server) Jscode2Session(code string) (ws *WxSession, err error) {
 	return
 }
 
+// Jscode2SessionCtx 小程序登录，用wx.login获取的js_code换取openid、session_key及unionid
+// code无效或已使用可通过util.IsErrCode(err, 40029)判断；session_key不能下发给客户端
+func (s *Server) Jscode2SessionCtx(ctx context.Context, jsCode string) (*WxSession, error) {
+	if jsCode == "" {
+		return nil, errors.New("wechat: empty js_code")
+	}
+	ws := new(WxSession)
+	if err := util.GetJsonCtx(ctx, fmt.Sprintf(WXAPIJscode2session, s.AppId, s.Secret, url.QueryEscape(jsCode)), ws); err != nil {
+		return nil, err
+	}
+	if err := ws.Error(); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
 // Jscode2SessionEnt code换session（企业微信）
 func (s *Server) Jscode2SessionEnt(code string) (ws *WxSession, err error) {
 	url := fmt.Sprintf(CorpAPIJscode2session, s.GetAccessToken(), code)
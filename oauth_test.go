@@ -0,0 +1,29 @@
+package wechat
+
+import "testing"
+
+func TestOauth2AuthCodeURL(t *testing.T) {
+	s := &Server{AppId: "wx520c15f417810387"}
+	cases := []struct {
+		redirect, scope, state string
+		want                   string
+	}{
+		{
+			"https://chong.qq.com/php/index.php?d=&c=wxAdapter&m=mobileDeal&showwxpaytitle=1&vb2ctag=4_2030_5_1194_60", Oauth2ScopeBase, "123",
+			"https://open.weixin.qq.com/connect/oauth2/authorize?appid=wx520c15f417810387&redirect_uri=https%3A%2F%2Fchong.qq.com%2Fphp%2Findex.php%3Fd%3D%26c%3DwxAdapter%26m%3DmobileDeal%26showwxpaytitle%3D1%26vb2ctag%3D4_2030_5_1194_60&response_type=code&scope=snsapi_base&state=123#wechat_redirect",
+		},
+		{
+			"http://example.com/cb", Oauth2ScopeUserInfo, "a b&c",
+			"https://open.weixin.qq.com/connect/oauth2/authorize?appid=wx520c15f417810387&redirect_uri=http%3A%2F%2Fexample.com%2Fcb&response_type=code&scope=snsapi_userinfo&state=a+b%26c#wechat_redirect",
+		},
+		{
+			"http://example.com/cb", "", "",
+			"https://open.weixin.qq.com/connect/oauth2/authorize?appid=wx520c15f417810387&redirect_uri=http%3A%2F%2Fexample.com%2Fcb&response_type=code&scope=snsapi_base&state=#wechat_redirect",
+		},
+	}
+	for _, c := range cases {
+		if got := s.Oauth2AuthCodeURL(c.redirect, c.scope, c.state); got != c.want {
+			t.Errorf("Oauth2AuthCodeURL(%q, %q, %q)\n got %s\nwant %s", c.redirect, c.scope, c.state, got, c.want)
+		}
+	}
+}
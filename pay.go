@@ -2,8 +2,18 @@
 package wechat
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
 	"fmt"
-
+	"hash"
+	"io"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/esap/wechat/util"
@@ -13,9 +23,213 @@ import (
 const (
 	PayRoot            = "weixin：//wxpay/bizpayurl?"
 	PayUrl             = "weixin：//wxpay/bizpayurl?sign=%s&appid=%s&mch_id=%s&product_id=%sX&time_stamp=%vX&nonce_str=%s"
-	PayUnifiedOrderUrl = "https://api.mch.weixin.qq.com/pay/unifiedordefunc"
+	PayUnifiedOrderUrl = "https://api.mch.weixin.qq.com/pay/unifiedorder"
 )
 
+// 微信支付v2签名类型
+const (
+	SignTypeMD5        = "MD5"
+	SignTypeHMACSHA256 = "HMAC-SHA256"
+)
+
+// 微信支付v2返回状态
+const (
+	PaySuccess = "SUCCESS"
+	PayFail    = "FAIL"
+)
+
+// PayParams 微信支付v2参数，按<xml><key>value</key></xml>格式编解码
+type PayParams map[string]string
+
+// MarshalXML 编码为<xml>格式，按key排序输出
+func (p PayParams) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "xml"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := e.EncodeElement(CDATA(p[k]), xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML 解析<xml>格式，忽略空值，值保留首尾空白，嵌套元素保留为原始xml
+func (p *PayParams) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if *p == nil {
+		*p = make(PayParams)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			var e struct {
+				Text  string `xml:",chardata"`
+				Inner string `xml:",innerxml"`
+			}
+			if err = d.DecodeElement(&e, &t); err != nil {
+				return err
+			}
+			// 保留原始文本，首尾空白同样参与签名，仅在判断空值及嵌套元素时去除空白
+			v := e.Text
+			if strings.TrimSpace(v) == "" {
+				v = ""
+				// 嵌套元素(如红包查询的hblist)保留原始xml，由调用方再解析
+				if inner := strings.TrimSpace(e.Inner); strings.HasPrefix(inner, "<") && !strings.HasPrefix(inner, "<![CDATA[") {
+					v = inner
+				}
+			}
+			if v != "" {
+				(*p)[t.Name.Local] = v
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// toPayParams 将带xml标签的结构体转为PayParams，空值字段被忽略
+func toPayParams(v interface{}) (PayParams, error) {
+	if p, ok := v.(PayParams); ok {
+		c := make(PayParams, len(p))
+		for k, v := range p {
+			c[k] = v
+		}
+		return c, nil
+	}
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	p := make(PayParams)
+	return p, xml.Unmarshal(b, &p)
+}
+
+// decode 将参数解析到带xml标签的结构体
+func (p PayParams) decode(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	b, err := xml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(b, v)
+}
+
+// PaySign 微信支付v2签名：按key排序拼接非空参数(不含sign)，追加&key=商户密钥后MD5或HMAC-SHA256，转大写
+// signType为空时使用MD5
+func PaySign(params map[string]string, key, signType string) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == "sign" || v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(params[k])
+		buf.WriteByte('&')
+	}
+	buf.WriteString("key=")
+	buf.WriteString(key)
+
+	var h hash.Hash
+	if signType == SignTypeHMACSHA256 {
+		h = hmac.New(sha256.New, []byte(key))
+	} else {
+		h = md5.New()
+	}
+	io.WriteString(h, buf.String())
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// PayVerifySign 校验参数中的sign，签名类型取自sign_type，为空时使用MD5
+func PayVerifySign(params map[string]string, key string) bool {
+	sign := params["sign"]
+	return sign != "" && hmac.Equal([]byte(sign), []byte(PaySign(params, key, params["sign_type"])))
+}
+
+// ErrPaySign 微信支付签名校验失败
+var ErrPaySign = errors.New("wechat pay: sign mismatch")
+
+// PayError 微信支付v2业务错误，return_code或result_code非SUCCESS
+type PayError struct {
+	ReturnCode string
+	ReturnMsg  string
+	ResultCode string
+	ErrCode    string
+	ErrCodeDes string
+}
+
+func (e *PayError) Error() string {
+	if e.ReturnCode != PaySuccess {
+		return fmt.Sprintf("wechat pay: return_code=%v , return_msg=%v", e.ReturnCode, e.ReturnMsg)
+	}
+	return fmt.Sprintf("wechat pay: err_code=%v , err_code_des=%v", e.ErrCode, e.ErrCodeDes)
+}
+
+// payRequest 发送微信支付v2请求：补全appid、mch_id、nonce_str并签名，校验返回签名及return_code、result_code后解析到ret
+// 返回缺少sign时视为签名错误
+func (s *Server) payRequest(ctx context.Context, c *util.Client, uri string, req interface{}, ret interface{}) error {
+	return s.doPayRequest(ctx, c, uri, req, ret, true)
+}
+
+// payRequestUnsigned 同payRequest，用于红包、企业付款等返回不带sign的接口，仅在返回带sign时校验
+func (s *Server) payRequestUnsigned(ctx context.Context, c *util.Client, uri string, req interface{}, ret interface{}) error {
+	return s.doPayRequest(ctx, c, uri, req, ret, false)
+}
+
+func (s *Server) doPayRequest(ctx context.Context, c *util.Client, uri string, req interface{}, ret interface{}, signed bool) error {
+	params, err := toPayParams(req)
+	if err != nil {
+		return err
+	}
+	if params["appid"] == "" && params["mch_appid"] == "" && params["wxappid"] == "" {
+		params["appid"] = s.AppId
+	}
+	if params["mch_id"] == "" && params["mchid"] == "" {
+		params["mch_id"] = s.MchId
+	}
+	if params["nonce_str"] == "" {
+		params["nonce_str"] = util.GetRandomString(32)
+	}
+	signType := params["sign_type"]
+	params["sign"] = PaySign(params, s.MchKey, signType)
+
+	resp := make(PayParams)
+	if err = c.PostXmlPtrCtx(ctx, uri, params, &resp); err != nil {
+		return err
+	}
+	if resp["return_code"] != PaySuccess {
+		return &PayError{ReturnCode: resp["return_code"], ReturnMsg: resp["return_msg"]}
+	}
+	if sign := resp["sign"]; sign == "" {
+		if signed {
+			return ErrPaySign
+		}
+	} else if !hmac.Equal([]byte(sign), []byte(PaySign(resp, s.MchKey, signType))) {
+		return ErrPaySign
+	}
+	if rc := resp["result_code"]; rc != "" && rc != PaySuccess {
+		return &PayError{ReturnCode: PaySuccess, ResultCode: rc, ErrCode: resp["err_code"], ErrCodeDes: resp["err_code_des"]}
+	}
+	return resp.decode(ret)
+}
+
 // UnifiedOrderReq 统一下单请求体
 type UnifiedOrderReq struct {
 	Appid          string `xml:"appid"`
@@ -70,12 +284,7 @@ type UnifiedOrderRet struct {
 
 // GetUnifedOrderUrl 获取统一下单URL，用于生成付款二维码等
 func (s *Server) GetUnifedOrderUrl(desc, tradeNo, fee, ip, callback, tradetype, productid string) string {
-	noncestr := util.GetRandomString(16)
 	r := &UnifiedOrderReq{
-		Appid:          s.AppId,
-		MchId:          s.MchId,
-		NonceStr:       noncestr,
-		Sign:           util.SortMd5(noncestr),
 		Body:           desc,
 		OutTradeNo:     tradeNo,
 		TotalFee:       fee,
@@ -84,8 +293,7 @@ func (s *Server) GetUnifedOrderUrl(desc, tradeNo, fee, ip, callback, tradetype,
 		TradeType:      tradetype,
 		ProductId:      productid,
 	}
-	ret := new(UnifiedOrderRet)
-	err := util.PostXmlPtr(PayUnifiedOrderUrl, r, ret)
+	ret, err := s.UnifiedOrderCtx(context.Background(), r)
 	if err != nil {
 		Println("GetUnifedOrderUrl err:", err)
 		return ""
@@ -93,6 +301,16 @@ func (s *Server) GetUnifedOrderUrl(desc, tradeNo, fee, ip, callback, tradetype,
 	return ret.CodeUrl
 }
 
+// UnifiedOrderCtx 统一下单，appid、mch_id、nonce_str为空时自动补全，并按SignType计算签名(默认MD5)
+// JSAPI支付时TradeType为JSAPI且需传入Openid，返回的PrepayId用于前端调起支付
+func (s *Server) UnifiedOrderCtx(ctx context.Context, req *UnifiedOrderReq) (*UnifiedOrderRet, error) {
+	ret := new(UnifiedOrderRet)
+	if err := s.payRequest(ctx, util.DefaultClient, PayUnifiedOrderUrl, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
 // PayOrderScan 扫码付
 func (s *Server) PayOrderScan(mchId, ProductId string) string {
 	nonceStr := util.GetRandomString(10)
@@ -100,3 +318,41 @@ func (s *Server) PayOrderScan(mchId, ProductId string) string {
 	strA := fmt.Sprintf("appid=%s&mch_id=%s&nonce_str=%s&product_id=%s&time_stamp=%v", s.AppId, mchId, nonceStr, ProductId, timeStamp)
 	return PayRoot + strA + "&sign=" + util.SortMd5(strA)
 }
+
+// PayJsApiParams JSAPI调起支付参数，json字段名与JS-SDK chooseWXPay/WeixinJSBridge要求一致
+type PayJsApiParams struct {
+	AppId     string `json:"appId"`
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// PayJsApiParams 根据统一下单返回的prepay_id生成前端调起支付的参数，signType为空时使用MD5，需与下单时一致
+func (s *Server) PayJsApiParams(prepayId, signType string) (*PayJsApiParams, error) {
+	if prepayId == "" {
+		return nil, errors.New("wechat pay: prepay_id is empty")
+	}
+	if s.MchKey == "" {
+		return nil, errors.New("wechat pay: MchKey is empty")
+	}
+	if signType == "" {
+		signType = SignTypeMD5
+	}
+	p := &PayJsApiParams{
+		AppId:     s.AppId,
+		TimeStamp: fmt.Sprint(time.Now().Unix()),
+		NonceStr:  util.GetRandomString(32),
+		Package:   "prepay_id=" + prepayId,
+		SignType:  signType,
+	}
+	p.PaySign = PaySign(map[string]string{
+		"appId":     p.AppId,
+		"timeStamp": p.TimeStamp,
+		"nonceStr":  p.NonceStr,
+		"package":   p.Package,
+		"signType":  p.SignType,
+	}, s.MchKey, signType)
+	return p, nil
+}
@@ -0,0 +1,69 @@
+package wechat
+
+import "encoding/xml"
+
+// PayNotify 支付结果通知
+type PayNotify struct {
+	ReturnCode    string `xml:"return_code"`
+	ReturnMsg     string `xml:"return_msg"`
+	Appid         string `xml:"appid"`
+	MchId         string `xml:"mch_id"`
+	DeviceInfo    string `xml:"device_info"`
+	NonceStr      string `xml:"nonce_str"`
+	Sign          string `xml:"sign"`
+	SignType      string `xml:"sign_type"`
+	ResultCode    string `xml:"result_code"`
+	ErrCode       string `xml:"err_code"`
+	ErrCodeDes    string `xml:"err_code_des"`
+	Openid        string `xml:"openid"`
+	IsSubscribe   string `xml:"is_subscribe"`
+	TradeType     string `xml:"trade_type"`
+	BankType      string `xml:"bank_type"`
+	TotalFee      int    `xml:"total_fee"`
+	SettlementFee int    `xml:"settlement_total_fee"`
+	FeeType       string `xml:"fee_type"`
+	CashFee       int    `xml:"cash_fee"`
+	CashFeeType   string `xml:"cash_fee_type"`
+	TransactionId string `xml:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no"`
+	Attach        string `xml:"attach"`
+	TimeEnd       string `xml:"time_end"`
+}
+
+// ParsePayNotify 解析支付结果通知并校验签名，签名不一致时返回ErrPaySign
+// 签名正确但return_code、result_code非SUCCESS时返回*PayError及解析结果
+func ParsePayNotify(body []byte, key string) (*PayNotify, error) {
+	p := make(PayParams)
+	if err := xml.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	if p["return_code"] != PaySuccess {
+		return nil, &PayError{ReturnCode: p["return_code"], ReturnMsg: p["return_msg"]}
+	}
+	if !PayVerifySign(p, key) {
+		return nil, ErrPaySign
+	}
+	n := new(PayNotify)
+	if err := p.decode(n); err != nil {
+		return nil, err
+	}
+	if n.ResultCode != PaySuccess {
+		return n, &PayError{ReturnCode: PaySuccess, ResultCode: n.ResultCode, ErrCode: n.ErrCode, ErrCodeDes: n.ErrCodeDes}
+	}
+	return n, nil
+}
+
+// ParsePayNotify 使用MchKey解析支付结果通知并校验签名
+func (s *Server) ParsePayNotify(body []byte) (*PayNotify, error) {
+	return ParsePayNotify(body, s.MchKey)
+}
+
+// PayNotifyAck 生成支付结果通知的应答，ok为false时msg为失败原因，微信会继续重发通知
+func PayNotifyAck(ok bool, msg string) []byte {
+	p := PayParams{"return_code": PaySuccess, "return_msg": "OK"}
+	if !ok {
+		p = PayParams{"return_code": PayFail, "return_msg": msg}
+	}
+	b, _ := xml.Marshal(p)
+	return b
+}
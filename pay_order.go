@@ -0,0 +1,75 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+
+	"github.com/esap/wechat/util"
+)
+
+// PayOrderQueryUrl 订单查询、关闭接口
+const (
+	PayOrderQueryUrl = "https://api.mch.weixin.qq.com/pay/orderquery"
+	PayCloseOrderUrl = "https://api.mch.weixin.qq.com/pay/closeorder"
+)
+
+// TradeState 订单交易状态
+type TradeState string
+
+// 交易状态
+const (
+	TradeSuccess    TradeState = "SUCCESS"    // 支付成功
+	TradeRefund     TradeState = "REFUND"     // 转入退款
+	TradeNotPay     TradeState = "NOTPAY"     // 未支付
+	TradeClosed     TradeState = "CLOSED"     // 已关闭
+	TradeRevoked    TradeState = "REVOKED"    // 已撤销(付款码支付)
+	TradeUserPaying TradeState = "USERPAYING" // 用户支付中(付款码支付)
+	TradePayError   TradeState = "PAYERROR"   // 支付失败
+)
+
+// PayOrderId 订单号，TransactionId与OutTradeNo二选一，同时设置时优先使用TransactionId
+type PayOrderId struct {
+	TransactionId string `xml:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no"`
+}
+
+// PayOrderQueryRet 订单查询返回体
+type PayOrderQueryRet struct {
+	Appid          string     `xml:"appid"`
+	MchId          string     `xml:"mch_id"`
+	DeviceInfo     string     `xml:"device_info"`
+	Openid         string     `xml:"openid"`
+	IsSubscribe    string     `xml:"is_subscribe"`
+	TradeType      string     `xml:"trade_type"`
+	TradeState     TradeState `xml:"trade_state"`
+	BankType       string     `xml:"bank_type"`
+	TotalFee       int        `xml:"total_fee"`
+	SettlementFee  int        `xml:"settlement_total_fee"`
+	FeeType        string     `xml:"fee_type"`
+	CashFee        int        `xml:"cash_fee"`
+	TransactionId  string     `xml:"transaction_id"`
+	OutTradeNo     string     `xml:"out_trade_no"`
+	Attach         string     `xml:"attach"`
+	TimeEnd        string     `xml:"time_end"`
+	TradeStateDesc string     `xml:"trade_state_desc"`
+}
+
+// PayOrderQueryCtx 查询订单
+func (s *Server) PayOrderQueryCtx(ctx context.Context, id PayOrderId) (*PayOrderQueryRet, error) {
+	if id.TransactionId == "" && id.OutTradeNo == "" {
+		return nil, errors.New("wechat pay: transaction_id or out_trade_no required")
+	}
+	if id.TransactionId != "" {
+		id.OutTradeNo = ""
+	}
+	ret := new(PayOrderQueryRet)
+	if err := s.payRequest(ctx, util.DefaultClient, PayOrderQueryUrl, id, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PayCloseOrderCtx 关闭订单，下单5分钟后才能关闭
+func (s *Server) PayCloseOrderCtx(ctx context.Context, outTradeNo string) error {
+	return s.payRequest(ctx, util.DefaultClient, PayCloseOrderUrl, PayOrderId{OutTradeNo: outTradeNo}, nil)
+}
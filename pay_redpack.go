@@ -0,0 +1,152 @@
+package wechat
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// 现金红包接口，均需要商户证书
+const (
+	PayRedPackUrl      = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendredpack"
+	PayGroupRedPackUrl = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendgroupredpack"
+	PayRedPackQueryUrl = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gethbinfo"
+)
+
+// 红包状态
+const (
+	RedPackSending   = "SENDING"
+	RedPackSent      = "SENT"
+	RedPackFailed    = "FAILED"
+	RedPackReceived  = "RECEIVED"
+	RedPackRefunding = "RFUND_ING"
+	RedPackRefund    = "REFUND"
+)
+
+// RedPackReq 发放红包请求体，金额单位为分
+// TotalNum为1时发放普通红包，大于1时发放裂变红包(3-20个)
+type RedPackReq struct {
+	MchBillno   string `xml:"mch_billno"` // 商户订单号，28位以内
+	WxAppId     string `xml:"wxappid"`    // 为空时使用Server.AppId
+	SendName    string `xml:"send_name"`
+	ReOpenId    string `xml:"re_openid"`
+	TotalAmount int    `xml:"total_amount"`
+	TotalNum    int    `xml:"total_num"`
+	AmtType     string `xml:"amt_type"` // 裂变红包金额设置方式，为空时使用ALL_RAND
+	Wishing     string `xml:"wishing"`
+	ClientIp    string `xml:"client_ip"` // 普通红包必填
+	ActName     string `xml:"act_name"`
+	Remark      string `xml:"remark"`
+	SceneId     string `xml:"scene_id"` // 金额大于200元或小于1元时必填
+	RiskInfo    string `xml:"risk_info"`
+}
+
+// RedPackResp 发放红包返回体
+type RedPackResp struct {
+	MchBillno   string `xml:"mch_billno"`
+	MchId       string `xml:"mch_id"`
+	WxAppId     string `xml:"wxappid"`
+	ReOpenId    string `xml:"re_openid"`
+	TotalAmount int    `xml:"total_amount"`
+	SendListId  string `xml:"send_listid"` // 微信红包单号
+}
+
+// SendRedPackCtx 发放现金红包，需配置MchCertFile、MchKeyFile
+func (s *Server) SendRedPackCtx(ctx context.Context, req *RedPackReq) (*RedPackResp, error) {
+	if req.MchBillno == "" || req.ReOpenId == "" {
+		return nil, errors.New("wechat pay: mch_billno and re_openid required")
+	}
+	if req.SendName == "" || req.Wishing == "" || req.ActName == "" || req.Remark == "" {
+		return nil, errors.New("wechat pay: send_name, wishing, act_name and remark required")
+	}
+	r := *req
+	if r.TotalNum <= 0 {
+		r.TotalNum = 1
+	}
+	if r.TotalAmount < r.TotalNum*100 {
+		return nil, fmt.Errorf("wechat pay: total_amount %d less than %d", r.TotalAmount, r.TotalNum*100)
+	}
+	uri := PayRedPackUrl
+	if r.TotalNum > 1 {
+		if r.TotalNum < 3 || r.TotalNum > 20 {
+			return nil, fmt.Errorf("wechat pay: group red pack total_num %d must be in 3-20", r.TotalNum)
+		}
+		if r.AmtType == "" {
+			r.AmtType = "ALL_RAND"
+		}
+		r.ClientIp = ""
+		uri = PayGroupRedPackUrl
+	} else {
+		if r.ClientIp == "" {
+			return nil, errors.New("wechat pay: client_ip required")
+		}
+		r.AmtType = ""
+	}
+	if r.WxAppId == "" {
+		r.WxAppId = s.AppId
+	}
+	c, err := s.PayCertClient()
+	if err != nil {
+		return nil, err
+	}
+	ret := new(RedPackResp)
+	if err = s.payRequestUnsigned(ctx, c, uri, &r, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// RedPackRecv 红包领取记录
+type RedPackRecv struct {
+	OpenId  string `xml:"openid"`
+	Amount  int    `xml:"amount"`
+	RcvTime string `xml:"rcv_time"`
+}
+
+// RedPackInfo 红包查询返回体
+type RedPackInfo struct {
+	MchBillno    string        `xml:"mch_billno"`
+	MchId        string        `xml:"mch_id"`
+	DetailId     string        `xml:"detail_id"`
+	Status       string        `xml:"status"`
+	SendType     string        `xml:"send_type"`
+	HbType       string        `xml:"hb_type"` // GROUP裂变红包，NORMAL普通红包
+	TotalNum     int           `xml:"total_num"`
+	TotalAmount  int           `xml:"total_amount"`
+	Reason       string        `xml:"reason"`
+	SendTime     string        `xml:"send_time"`
+	RefundTime   string        `xml:"refund_time"`
+	RefundAmount int           `xml:"refund_amount"`
+	Wishing      string        `xml:"wishing"`
+	Remark       string        `xml:"remark"`
+	ActName      string        `xml:"act_name"`
+	HbList       []RedPackRecv `xml:"-"`
+}
+
+// RedPackQueryCtx 按商户订单号查询红包记录，需配置MchCertFile、MchKeyFile
+func (s *Server) RedPackQueryCtx(ctx context.Context, mchBillno string) (*RedPackInfo, error) {
+	c, err := s.PayCertClient()
+	if err != nil {
+		return nil, err
+	}
+	req := PayParams{"mch_billno": mchBillno, "bill_type": "MCHT"}
+	p := make(PayParams)
+	if err = s.payRequestUnsigned(ctx, c, PayRedPackQueryUrl, req, &p); err != nil {
+		return nil, err
+	}
+	ret := new(RedPackInfo)
+	if err = p.decode(ret); err != nil {
+		return nil, err
+	}
+	if list := p["hblist"]; list != "" {
+		var hb struct {
+			Items []RedPackRecv `xml:"hbinfo"`
+		}
+		if err = xml.Unmarshal([]byte("<hblist>"+list+"</hblist>"), &hb); err != nil {
+			return nil, err
+		}
+		ret.HbList = hb.Items
+	}
+	return ret, nil
+}
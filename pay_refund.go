@@ -0,0 +1,147 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/esap/wechat/util"
+)
+
+// PayRefundUrl 退款接口，申请退款需要商户证书
+const (
+	PayRefundUrl      = "https://api.mch.weixin.qq.com/secapi/pay/refund"
+	PayRefundQueryUrl = "https://api.mch.weixin.qq.com/pay/refundquery"
+)
+
+// PayCertClient 返回加载了商户证书的Client，用于退款、红包、企业付款等接口
+func (s *Server) PayCertClient() (*util.Client, error) {
+	s.payCertOnce.Do(func() {
+		if s.MchCertFile == "" || s.MchKeyFile == "" {
+			s.payCertErr = errors.New("wechat pay: MchCertFile and MchKeyFile required")
+			return
+		}
+		cfg, err := util.NewTLSConfig(s.MchCertFile, s.MchKeyFile, "")
+		if err != nil {
+			s.payCertErr = err
+			return
+		}
+		s.payCert = util.NewClient(util.WithClientTLSConfig(cfg))
+	})
+	return s.payCert, s.payCertErr
+}
+
+// PayRefundReq 申请退款请求体，TransactionId与OutTradeNo二选一，RefundFee小于TotalFee时为部分退款
+type PayRefundReq struct {
+	TransactionId string `xml:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no"`
+	OutRefundNo   string `xml:"out_refund_no"`
+	TotalFee      int    `xml:"total_fee"`
+	RefundFee     int    `xml:"refund_fee"`
+	RefundFeeType string `xml:"refund_fee_type"`
+	RefundDesc    string `xml:"refund_desc"`
+	RefundAccount string `xml:"refund_account"`
+	NotifyUrl     string `xml:"notify_url"`
+	SignType      string `xml:"sign_type"`
+}
+
+// PayRefundRet 申请退款返回体
+type PayRefundRet struct {
+	TransactionId     string `xml:"transaction_id"`
+	OutTradeNo        string `xml:"out_trade_no"`
+	OutRefundNo       string `xml:"out_refund_no"`
+	RefundId          string `xml:"refund_id"`
+	RefundFee         int    `xml:"refund_fee"`
+	SettlementRefund  int    `xml:"settlement_refund_fee"`
+	TotalFee          int    `xml:"total_fee"`
+	SettlementTotal   int    `xml:"settlement_total_fee"`
+	FeeType           string `xml:"fee_type"`
+	CashFee           int    `xml:"cash_fee"`
+	CashRefundFee     int    `xml:"cash_refund_fee"`
+	CouponRefundFee   int    `xml:"coupon_refund_fee"`
+	CouponRefundCount int    `xml:"coupon_refund_count"`
+}
+
+// PayRefundCtx 申请退款，需配置MchCertFile、MchKeyFile
+func (s *Server) PayRefundCtx(ctx context.Context, req *PayRefundReq) (*PayRefundRet, error) {
+	if req.TransactionId == "" && req.OutTradeNo == "" {
+		return nil, errors.New("wechat pay: transaction_id or out_trade_no required")
+	}
+	if req.RefundFee <= 0 || req.RefundFee > req.TotalFee {
+		return nil, fmt.Errorf("wechat pay: refund_fee %d must be in 1-%d", req.RefundFee, req.TotalFee)
+	}
+	c, err := s.PayCertClient()
+	if err != nil {
+		return nil, err
+	}
+	ret := new(PayRefundRet)
+	if err = s.payRequest(ctx, c, PayRefundUrl, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PayRefundQueryReq 查询退款请求体，四个单号任选其一，优先级refund_id>out_refund_no>transaction_id>out_trade_no
+type PayRefundQueryReq struct {
+	TransactionId string `xml:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no"`
+	OutRefundNo   string `xml:"out_refund_no"`
+	RefundId      string `xml:"refund_id"`
+	Offset        string `xml:"offset"` // 退款笔数超过10笔时分页查询的偏移量
+}
+
+// PayRefundItem 单笔退款记录
+type PayRefundItem struct {
+	OutRefundNo       string
+	RefundId          string
+	RefundChannel     string
+	RefundFee         int
+	RefundStatus      string // SUCCESS、REFUNDCLOSE、PROCESSING、CHANGE
+	RefundAccount     string
+	RefundRecvAccount string
+	RefundSuccessTime string
+}
+
+// PayRefundQueryRet 查询退款返回体
+type PayRefundQueryRet struct {
+	TransactionId string
+	OutTradeNo    string
+	TotalFee      int
+	CashFee       int
+	RefundCount   int
+	Refunds       []PayRefundItem
+}
+
+// PayRefundQueryCtx 查询退款
+func (s *Server) PayRefundQueryCtx(ctx context.Context, req *PayRefundQueryReq) (*PayRefundQueryRet, error) {
+	p := make(PayParams)
+	if err := s.payRequest(ctx, util.DefaultClient, PayRefundQueryUrl, req, &p); err != nil {
+		return nil, err
+	}
+	atoi := func(k string) int {
+		n, _ := strconv.Atoi(p[k])
+		return n
+	}
+	ret := &PayRefundQueryRet{
+		TransactionId: p["transaction_id"],
+		OutTradeNo:    p["out_trade_no"],
+		TotalFee:      atoi("total_fee"),
+		CashFee:       atoi("cash_fee"),
+		RefundCount:   atoi("refund_count"),
+	}
+	for i := 0; i < ret.RefundCount; i++ {
+		n := "_" + strconv.Itoa(i)
+		ret.Refunds = append(ret.Refunds, PayRefundItem{
+			OutRefundNo:       p["out_refund_no"+n],
+			RefundId:          p["refund_id"+n],
+			RefundChannel:     p["refund_channel"+n],
+			RefundFee:         atoi("refund_fee" + n),
+			RefundStatus:      p["refund_status"+n],
+			RefundAccount:     p["refund_account"+n],
+			RefundRecvAccount: p["refund_recv_accout"+n],
+			RefundSuccessTime: p["refund_success_time"+n],
+		})
+	}
+	return ret, nil
+}
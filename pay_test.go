@@ -0,0 +1,121 @@
+package wechat
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/esap/wechat/util"
+)
+
+// 官方文档签名示例 https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=4_3
+var paySignParams = map[string]string{
+	"appid":       "wxd930ea5d5a258f4f",
+	"mch_id":      "10000100",
+	"device_info": "1000",
+	"body":        "test",
+	"nonce_str":   "ibuaiVcKdpRxkhJA",
+}
+
+const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+
+func TestPaySign(t *testing.T) {
+	cases := []struct {
+		signType, want string
+	}{
+		{"", "9A0A8659F005D6984697E2CA0A9CF3B7"},
+		{SignTypeMD5, "9A0A8659F005D6984697E2CA0A9CF3B7"},
+		{SignTypeHMACSHA256, "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6"},
+	}
+	for _, c := range cases {
+		if got := PaySign(paySignParams, paySignKey, c.signType); got != c.want {
+			t.Errorf("PaySign(%q) = %v, want %v", c.signType, got, c.want)
+		}
+	}
+}
+
+func TestPaySignIgnoresSignAndEmpty(t *testing.T) {
+	p := map[string]string{"sign": "XXX", "attach": ""}
+	for k, v := range paySignParams {
+		p[k] = v
+	}
+	if got := PaySign(p, paySignKey, ""); got != "9A0A8659F005D6984697E2CA0A9CF3B7" {
+		t.Errorf("PaySign = %v", got)
+	}
+}
+
+func TestPayVerifySign(t *testing.T) {
+	p := map[string]string{"sign": "9A0A8659F005D6984697E2CA0A9CF3B7"}
+	for k, v := range paySignParams {
+		p[k] = v
+	}
+	if !PayVerifySign(p, paySignKey) {
+		t.Error("expected valid sign")
+	}
+	p["body"] = "tampered"
+	if PayVerifySign(p, paySignKey) {
+		t.Error("expected invalid sign after tampering")
+	}
+	delete(p, "sign")
+	if PayVerifySign(p, paySignKey) {
+		t.Error("expected invalid sign when missing")
+	}
+}
+
+func TestPayParamsUnmarshal(t *testing.T) {
+	body := `<xml><return_code><![CDATA[SUCCESS]]></return_code><total_num>1</total_num><remark><![CDATA[]]></remark>` +
+		`<hblist><hbinfo><openid><![CDATA[oTtS]]></openid><amount>100</amount></hbinfo></hblist></xml>`
+	p := make(PayParams)
+	if err := xml.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p["return_code"] != PaySuccess || p["total_num"] != "1" {
+		t.Errorf("unexpected params: %v", p)
+	}
+	if _, ok := p["remark"]; ok {
+		t.Error("empty value should be ignored")
+	}
+	if p["hblist"] != "<hbinfo><openid><![CDATA[oTtS]]></openid><amount>100</amount></hbinfo>" {
+		t.Errorf("hblist = %q", p["hblist"])
+	}
+}
+
+func TestPayRequestMissingSign(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><prepay_id>wx201410272009395522657a690389285100</prepay_id></xml>`))
+	}))
+	defer ts.Close()
+
+	s := &Server{AppId: "wxd930ea5d5a258f4f", MchId: "10000100", MchKey: paySignKey}
+	ret := make(PayParams)
+	if err := s.payRequest(context.Background(), util.DefaultClient, ts.URL, PayParams{"body": "test"}, &ret); err != ErrPaySign {
+		t.Errorf("expected ErrPaySign, got %v", err)
+	}
+	if err := s.payRequestUnsigned(context.Background(), util.DefaultClient, ts.URL, PayParams{"body": "test"}, &ret); err != nil {
+		t.Fatal(err)
+	}
+	if ret["prepay_id"] != "wx201410272009395522657a690389285100" {
+		t.Errorf("unexpected response: %v", ret)
+	}
+}
+
+func TestPayParamsKeepWhitespace(t *testing.T) {
+	params := PayParams{"appid": "wxd930ea5d5a258f4f", "attach": " 深圳分店 ", "body": "test\n"}
+	params["sign"] = PaySign(params, paySignKey, "")
+	b, err := xml.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make(PayParams)
+	if err = xml.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p["attach"] != " 深圳分店 " || p["body"] != "test\n" {
+		t.Errorf("whitespace not preserved: %q %q", p["attach"], p["body"])
+	}
+	if !PayVerifySign(p, paySignKey) {
+		t.Error("signature of values with surrounding whitespace rejected")
+	}
+}
@@ -0,0 +1,123 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 企业付款到零钱接口，均需要商户证书
+const (
+	PayTransferUrl      = "https://api.mch.weixin.qq.com/mmpaymkttransfers/promotion/transfers"
+	PayTransferQueryUrl = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gettransferinfo"
+)
+
+// 企业付款校验用户姓名选项
+const (
+	TransferNoCheck     = "NO_CHECK"     // 不校验真实姓名
+	TransferForceCheck  = "FORCE_CHECK"  // 强校验真实姓名，需填写ReUserName
+	TransferOptionCheck = "OPTION_CHECK" // 针对已实名认证的用户才校验真实姓名(旧版)
+)
+
+// 企业付款状态
+const (
+	TransferSuccess    = "SUCCESS"
+	TransferFailed     = "FAILED"
+	TransferProcessing = "PROCESSING"
+)
+
+// TransferReq 企业付款请求体，金额单位为分
+type TransferReq struct {
+	MchAppId       string `xml:"mch_appid"` // 为空时使用Server.AppId
+	MchId          string `xml:"mchid"`     // 为空时使用Server.MchId
+	DeviceInfo     string `xml:"device_info"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	OpenId         string `xml:"openid"`
+	CheckName      string `xml:"check_name"` // 为空时使用NO_CHECK
+	ReUserName     string `xml:"re_user_name"`
+	Amount         int    `xml:"amount"`
+	Desc           string `xml:"desc"`
+	SpbillCreateIp string `xml:"spbill_create_ip"`
+}
+
+// TransferResp 企业付款返回体
+type TransferResp struct {
+	MchAppId       string `xml:"mch_appid"`
+	MchId          string `xml:"mchid"`
+	DeviceInfo     string `xml:"device_info"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	PaymentNo      string `xml:"payment_no"` // 微信付款单号
+	PaymentTime    string `xml:"payment_time"`
+}
+
+// TransferToBalanceCtx 企业付款到用户零钱，需配置MchCertFile、MchKeyFile
+func (s *Server) TransferToBalanceCtx(ctx context.Context, req *TransferReq) (*TransferResp, error) {
+	if req.PartnerTradeNo == "" || req.OpenId == "" {
+		return nil, errors.New("wechat pay: partner_trade_no and openid required")
+	}
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("wechat pay: invalid amount %d", req.Amount)
+	}
+	if req.Desc == "" {
+		return nil, errors.New("wechat pay: desc required")
+	}
+	r := *req
+	switch r.CheckName {
+	case "":
+		r.CheckName = TransferNoCheck
+	case TransferNoCheck:
+	case TransferForceCheck, TransferOptionCheck:
+		if r.ReUserName == "" {
+			return nil, fmt.Errorf("wechat pay: re_user_name required when check_name=%v", r.CheckName)
+		}
+	default:
+		return nil, fmt.Errorf("wechat pay: invalid check_name %v", r.CheckName)
+	}
+	if r.MchAppId == "" {
+		r.MchAppId = s.AppId
+	}
+	if r.MchId == "" {
+		r.MchId = s.MchId
+	}
+	c, err := s.PayCertClient()
+	if err != nil {
+		return nil, err
+	}
+	ret := new(TransferResp)
+	if err = s.payRequestUnsigned(ctx, c, PayTransferUrl, &r, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TransferInfo 企业付款查询返回体
+type TransferInfo struct {
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	AppId          string `xml:"appid"`
+	MchId          string `xml:"mch_id"`
+	DetailId       string `xml:"detail_id"` // 微信付款单号
+	Status         string `xml:"status"`
+	Reason         string `xml:"reason"`
+	OpenId         string `xml:"openid"`
+	TransferName   string `xml:"transfer_name"`
+	PaymentAmount  int    `xml:"payment_amount"`
+	TransferTime   string `xml:"transfer_time"`
+	PaymentTime    string `xml:"payment_time"`
+	Desc           string `xml:"desc"`
+}
+
+// TransferQueryCtx 按商户订单号查询企业付款，需配置MchCertFile、MchKeyFile
+func (s *Server) TransferQueryCtx(ctx context.Context, partnerTradeNo string) (*TransferInfo, error) {
+	if partnerTradeNo == "" {
+		return nil, errors.New("wechat pay: partner_trade_no required")
+	}
+	c, err := s.PayCertClient()
+	if err != nil {
+		return nil, err
+	}
+	ret := new(TransferInfo)
+	if err = s.payRequestUnsigned(ctx, c, PayTransferQueryUrl, PayParams{"partner_trade_no": partnerTradeNo}, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
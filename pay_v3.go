@@ -0,0 +1,192 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/esap/wechat/util"
+)
+
+// PayV3Root 微信支付v3接口根地址
+const PayV3Root = "https://api.mch.weixin.qq.com"
+
+// PayV3AuthSchema 微信支付v3签名认证类型
+const PayV3AuthSchema = "WECHATPAY2-SHA256-RSA2048"
+
+// PayV3 微信支付v3客户端，使用商户私钥对每个请求签名
+type PayV3 struct {
+	MchId    string       // 商户号
+	SerialNo string       // 商户API证书序列号
+	ApiV3Key string       // APIv3密钥，用于解密平台证书及回调通知
+	Client   *util.Client // 发送请求的Client，为nil时使用util.DefaultClient
+
+	// SkipVerify 为true时不校验应答签名，未设置ApiV3Key又需调用Do时显式开启
+	SkipVerify bool
+
+	privateKey *rsa.PrivateKey
+
+	certMu sync.RWMutex
+	certs  map[string]*PayV3Cert // 平台证书，按序列号索引
+	certAt time.Time             // 上次下载平台证书的时间
+	missAt time.Time             // 上次因未知序列号下载平台证书的时间
+	soonAt time.Time             // 上次提前刷新平台证书的时间
+	dl     *payV3CertCall        // 进行中的证书下载，并发调用共享
+}
+
+// NewPayV3 创建微信支付v3客户端，privateKeyPEM为商户API证书私钥apiclient_key.pem的内容
+func NewPayV3(mchId, serialNo, apiV3Key string, privateKeyPEM []byte) (*PayV3, error) {
+	key, err := parseRSAPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &PayV3{MchId: mchId, SerialNo: serialNo, ApiV3Key: apiV3Key, privateKey: key}, nil
+}
+
+// LoadPayV3 从私钥文件创建微信支付v3客户端
+func LoadPayV3(mchId, serialNo, apiV3Key, privateKeyFile string) (*PayV3, error) {
+	b, err := ioutil.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewPayV3(mchId, serialNo, apiV3Key, b)
+}
+
+// parseRSAPrivateKey 解析PKCS#8或PKCS#1格式的PEM私钥
+func parseRSAPrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("wechat pay v3: invalid private key pem")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("wechat pay v3: private key is not rsa")
+	}
+	return key, nil
+}
+
+// SignRequest 计算请求签名，返回Authorization请求头的值
+// urlPath为不含域名的路径及查询参数，如/v3/certificates
+func (p *PayV3) SignRequest(method, urlPath string, body []byte) (string, error) {
+	nonce := util.GetRandomString(32)
+	timestamp := time.Now().Unix()
+	msg := fmt.Sprintf("%s\n%s\n%d\n%s\n%s\n", method, urlPath, timestamp, nonce, body)
+	sign, err := p.sign(msg)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%d",serial_no="%s"`,
+		PayV3AuthSchema, p.MchId, nonce, sign, timestamp, p.SerialNo), nil
+}
+
+// sign SHA256withRSA签名并base64编码
+func (p *PayV3) sign(msg string) (string, error) {
+	h := sha256.Sum256([]byte(msg))
+	b, err := rsa.SignPKCS1v15(rand.Reader, p.privateKey, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// ErrPayV3NoApiV3Key 未设置ApiV3Key，无法下载平台证书校验应答签名
+var ErrPayV3NoApiV3Key = errors.New("wechat pay v3: ApiV3Key is required to verify response signature")
+
+// PayV3Error 微信支付v3接口错误
+type PayV3Error struct {
+	StatusCode int
+	Code       string          `json:"code"`
+	Message    string          `json:"message"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+}
+
+func (e *PayV3Error) Error() string {
+	return fmt.Sprintf("wechat pay v3: statusCode=%v , code=%v , message=%v", e.StatusCode, e.Code, e.Message)
+}
+
+// Do 发送签名的v3请求，req不为nil时编码为json请求体，2xx响应解析到ret，其他状态返回*PayV3Error
+// path为以/v3开头的路径，应答先使用平台证书校验签名，通过后才解析到ret
+// 未设置ApiV3Key时返回ErrPayV3NoApiV3Key，除非设置了SkipVerify
+func (p *PayV3) Do(ctx context.Context, method, path string, req, ret interface{}) error {
+	if p.ApiV3Key == "" && !p.SkipVerify {
+		return ErrPayV3NoApiV3Key
+	}
+	h, body, err := p.do(ctx, method, path, req)
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	if !p.SkipVerify {
+		if err = p.VerifySignature(ctx, h, body); err != nil {
+			return err
+		}
+	}
+	if ret != nil {
+		return json.Unmarshal(body, ret)
+	}
+	return nil
+}
+
+// do 发送签名的v3请求，返回响应头及未经校验的原始响应体
+func (p *PayV3) do(ctx context.Context, method, path string, req interface{}) (http.Header, []byte, error) {
+	var body []byte
+	var r io.Reader
+	ct := ""
+	if req != nil {
+		var err error
+		if body, err = json.Marshal(req); err != nil {
+			return nil, nil, err
+		}
+		r = bytes.NewReader(body)
+		ct = "application/json"
+	}
+	u, err := url.Parse(PayV3Root + path)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := p.SignRequest(method, u.RequestURI(), body)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := http.Header{"Authorization": {auth}, "Accept": {"application/json"}}
+	c := p.Client
+	if c == nil {
+		c = util.DefaultClient
+	}
+	resp, err := c.DoCtx(ctx, method, u.String(), ct, r, util.WithHeaders(h))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(c.LimitBody(resp.Body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &PayV3Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(b, e)
+		return resp.Header, b, e
+	}
+	return resp.Header, b, nil
+}
@@ -0,0 +1,220 @@
+package wechat
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PayV3CertUrl 平台证书下载接口
+const PayV3CertUrl = "/v3/certificates"
+
+// PayV3CertRefresh 平台证书刷新间隔，微信建议定期下载以获取新证书
+var PayV3CertRefresh = 12 * time.Hour
+
+// PayV3SignatureMaxSkew 应答签名时间戳允许的最大偏差
+var PayV3SignatureMaxSkew = 5 * time.Minute
+
+// ErrPayV3Signature 微信支付v3应答或回调签名校验失败
+var ErrPayV3Signature = errors.New("wechat pay v3: signature mismatch")
+
+// payV3EncryptData AEAD_AES_256_GCM加密数据
+type payV3EncryptData struct {
+	Algorithm      string `json:"algorithm"`
+	Nonce          string `json:"nonce"`
+	AssociatedData string `json:"associated_data"`
+	Ciphertext     string `json:"ciphertext"`
+	OriginalType   string `json:"original_type,omitempty"`
+}
+
+// DecryptAEAD 使用APIv3密钥解密AEAD_AES_256_GCM数据，ciphertext为base64编码且包含认证标签
+func (p *PayV3) DecryptAEAD(nonce, associatedData, ciphertext string) ([]byte, error) {
+	if len(p.ApiV3Key) != 32 {
+		return nil, errors.New("wechat pay v3: ApiV3Key must be 32 bytes")
+	}
+	b, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(p.ApiV3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, []byte(nonce), b, []byte(associatedData))
+}
+
+// PayV3Cert 平台证书
+type PayV3Cert struct {
+	SerialNo      string
+	EffectiveTime time.Time
+	ExpireTime    time.Time
+	Certificate   *x509.Certificate
+}
+
+// DownloadCertificates 下载并解密平台证书，校验应答签名后缓存，返回全部有效证书
+func (p *PayV3) DownloadCertificates(ctx context.Context) ([]*PayV3Cert, error) {
+	ret := new(struct {
+		Data []struct {
+			SerialNo           string           `json:"serial_no"`
+			EffectiveTime      time.Time        `json:"effective_time"`
+			ExpireTime         time.Time        `json:"expire_time"`
+			EncryptCertificate payV3EncryptData `json:"encrypt_certificate"`
+		} `json:"data"`
+	})
+	h, body, err := p.do(ctx, http.MethodGet, PayV3CertUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(body, ret); err != nil {
+		return nil, err
+	}
+	certs := make(map[string]*PayV3Cert, len(ret.Data))
+	list := make([]*PayV3Cert, 0, len(ret.Data))
+	for _, d := range ret.Data {
+		e := d.EncryptCertificate
+		b, err := p.DecryptAEAD(e.Nonce, e.AssociatedData, e.Ciphertext)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("wechat pay v3: invalid certificate pem")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		c := &PayV3Cert{SerialNo: d.SerialNo, EffectiveTime: d.EffectiveTime, ExpireTime: d.ExpireTime, Certificate: cert}
+		certs[d.SerialNo] = c
+		list = append(list, c)
+	}
+	// 证书下载接口的应答使用其中的证书签名
+	if err = verifyPayV3Signature(certs, h, body); err != nil {
+		return nil, err
+	}
+	p.certMu.Lock()
+	p.certs = certs
+	p.certAt = time.Now()
+	p.certMu.Unlock()
+	return list, nil
+}
+
+// payV3CertCall 进行中的平台证书下载
+type payV3CertCall struct {
+	done chan struct{}
+	err  error
+}
+
+// certificate 返回指定序列号的平台证书
+// 缓存的证书未过期时直接返回，缓存过期或证书即将过期时每个刷新周期至多在后台刷新一次；
+// 序列号来自调用方，未知序列号在每个刷新周期内至多触发一次下载，避免伪造回调反复请求证书接口
+func (p *PayV3) certificate(ctx context.Context, serial string) (*PayV3Cert, error) {
+	now := time.Now()
+	p.certMu.Lock()
+	c, ok := p.certs[serial]
+	fresh := now.Sub(p.certAt) < PayV3CertRefresh
+	if ok && now.Before(c.ExpireTime) {
+		if (!fresh || c.ExpireTime.Sub(now) < PayV3CertRefresh) && now.Sub(p.soonAt) >= PayV3CertRefresh {
+			p.soonAt = now
+			go p.refreshCertificates(context.Background())
+		}
+		p.certMu.Unlock()
+		return c, nil
+	}
+	if fresh {
+		if now.Sub(p.missAt) < PayV3CertRefresh {
+			p.certMu.Unlock()
+			return nil, ErrPayV3Signature
+		}
+		p.missAt = now
+	}
+	p.certMu.Unlock()
+	if err := p.refreshCertificates(ctx); err != nil {
+		return nil, err
+	}
+	p.certMu.RLock()
+	defer p.certMu.RUnlock()
+	if c, ok = p.certs[serial]; !ok || !time.Now().Before(c.ExpireTime) {
+		return nil, ErrPayV3Signature
+	}
+	return c, nil
+}
+
+// refreshCertificates 下载平台证书，并发调用等待同一次下载的结果
+func (p *PayV3) refreshCertificates(ctx context.Context) error {
+	p.certMu.Lock()
+	call := p.dl
+	if call == nil {
+		call = &payV3CertCall{done: make(chan struct{})}
+		p.dl = call
+		p.certMu.Unlock()
+		_, call.err = p.DownloadCertificates(ctx)
+		p.certMu.Lock()
+		p.dl = nil
+		p.certMu.Unlock()
+		close(call.done)
+		return call.err
+	}
+	p.certMu.Unlock()
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// VerifySignature 使用平台证书校验应答或回调通知的Wechatpay-Signature签名
+func (p *PayV3) VerifySignature(ctx context.Context, h http.Header, body []byte) error {
+	c, err := p.certificate(ctx, h.Get("Wechatpay-Serial"))
+	if err != nil {
+		return err
+	}
+	return verifyPayV3Signature(map[string]*PayV3Cert{c.SerialNo: c}, h, body)
+}
+
+// verifyPayV3Signature 校验签名串：应答时间戳\n应答随机串\n应答报文主体\n
+func verifyPayV3Signature(certs map[string]*PayV3Cert, h http.Header, body []byte) error {
+	c, ok := certs[h.Get("Wechatpay-Serial")]
+	if !ok {
+		return fmt.Errorf("wechat pay v3: unknown platform certificate %v", h.Get("Wechatpay-Serial"))
+	}
+	ts := h.Get("Wechatpay-Timestamp")
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrPayV3Signature
+	}
+	if d := time.Since(time.Unix(n, 0)); d > PayV3SignatureMaxSkew || d < -PayV3SignatureMaxSkew {
+		return fmt.Errorf("wechat pay v3: signature timestamp %v expired", ts)
+	}
+	sig, err := base64.StdEncoding.DecodeString(h.Get("Wechatpay-Signature"))
+	if err != nil {
+		return ErrPayV3Signature
+	}
+	pub, ok := c.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("wechat pay v3: platform certificate is not rsa")
+	}
+	msg := fmt.Sprintf("%s\n%s\n%s\n", ts, h.Get("Wechatpay-Nonce"), body)
+	sum := sha256.Sum256([]byte(msg))
+	if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+		return ErrPayV3Signature
+	}
+	return nil
+}
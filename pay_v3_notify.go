@@ -0,0 +1,82 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// PayV3Notify 微信支付v3回调通知
+type PayV3Notify struct {
+	Id           string           `json:"id"`
+	CreateTime   time.Time        `json:"create_time"`
+	EventType    string           `json:"event_type"` // 如TRANSACTION.SUCCESS、REFUND.SUCCESS
+	ResourceType string           `json:"resource_type"`
+	Resource     payV3EncryptData `json:"resource"`
+	Summary      string           `json:"summary"`
+}
+
+// PayV3Transaction 支付成功通知解密后的交易信息
+type PayV3Transaction struct {
+	AppId          string `json:"appid"`
+	MchId          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`
+	TransactionId  string `json:"transaction_id"`
+	TradeType      string `json:"trade_type"`
+	TradeState     string `json:"trade_state"`
+	TradeStateDesc string `json:"trade_state_desc"`
+	BankType       string `json:"bank_type"`
+	Attach         string `json:"attach"`
+	SuccessTime    string `json:"success_time"`
+	Payer          struct {
+		OpenId string `json:"openid"`
+	} `json:"payer"`
+	Amount struct {
+		Total         int    `json:"total"`
+		PayerTotal    int    `json:"payer_total"`
+		Currency      string `json:"currency"`
+		PayerCurrency string `json:"payer_currency"`
+	} `json:"amount"`
+}
+
+// ParseNotify 校验回调通知签名并解密resource到v，返回通知本身
+func (p *PayV3) ParseNotify(ctx context.Context, h http.Header, body []byte, v interface{}) (*PayV3Notify, error) {
+	if err := p.VerifySignature(ctx, h, body); err != nil {
+		return nil, err
+	}
+	n := new(PayV3Notify)
+	if err := json.Unmarshal(body, n); err != nil {
+		return nil, err
+	}
+	r := n.Resource
+	b, err := p.DecryptAEAD(r.Nonce, r.AssociatedData, r.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		if err = json.Unmarshal(b, v); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// DecryptNotify 校验支付成功通知签名并解密交易信息，h为回调请求头
+func (p *PayV3) DecryptNotify(ctx context.Context, h http.Header, body []byte) (*PayV3Transaction, error) {
+	t := new(PayV3Transaction)
+	if _, err := p.ParseNotify(ctx, h, body, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// PayV3NotifyAck 生成回调通知的应答，ok为false时微信会重发通知，此时应答状态码应为非2xx
+func PayV3NotifyAck(ok bool, msg string) []byte {
+	ack := map[string]string{"code": "SUCCESS", "message": "成功"}
+	if !ok {
+		ack = map[string]string{"code": "FAIL", "message": msg}
+	}
+	b, _ := json.Marshal(ack)
+	return b
+}
@@ -0,0 +1,234 @@
+package wechat
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/esap/wechat/util"
+)
+
+// 由AES-256-GCM按下列参数生成的测试向量
+const (
+	testApiV3Key     = "0123456789abcdef0123456789abcdef"
+	testAEADNonce    = "fdasflkja484"
+	testAEADAssoc    = "transaction"
+	testAEADCipher   = "DUcSk7X3A8MEOkED4MXRyFI8lVzmxofHCgQTJTZp7Le7Yh4fwJp7KJjRO4KcjLgLU6SrqBdzgGy/sMCWIPKVQdMoXpY4xG4iYhF7TH0MBs4T7FaM9hoFjn/sa9TCWtjLTRzZiknVkW9IXW37a35VmVaeZyEYg+wBm3qpNS8A5dUAMLCno0J6LHDN5GXutOi+aMmWJMtSbZltG3H2Cu2Vou2Js2PVFnyTfOk0kYJAGpoCL2ommjUrvbBvauITRk+g7Dfm7+bPqpCyLxE9P+DHSG8gs2hpYZEd3Y4SsUQ5B3wHGf2nf5LEC67a4c/DylB6Sxo84CUmsT5t5Zzx3B3mfRz0Rgtt8OTyKaOuRTdurk8rABe7bHZIVJrEBivM040B5QX/dXUWTfwXYE5EnTtlcV8pjGF4fK1d4LO6jjl79WVe4BZb6Z41tfkt75WSeCmqRw=="
+	testAEADPlain    = `{"appid":"wxd678efh567hg6787","mchid":"1230000109","out_trade_no":"1217752501201407033233368018","transaction_id":"1217752501201407033233368018","trade_type":"JSAPI","trade_state":"SUCCESS","payer":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"amount":{"total":100,"payer_total":100,"currency":"CNY","payer_currency":"CNY"}}`
+	testPlatformSN   = "5157F09EFDC096DE15EBE81A47057A7232F1B8E1"
+	testNotifyFormat = `{"id":"EV-2018022511223320873","create_time":"2015-05-20T13:29:35+08:00","resource_type":"encrypt-resource","event_type":"TRANSACTION.SUCCESS","summary":"支付成功","resource":{"original_type":"transaction","algorithm":"AEAD_AES_256_GCM","ciphertext":"%s","associated_data":"%s","nonce":"%s"}}`
+)
+
+func TestPayV3DecryptAEAD(t *testing.T) {
+	p := &PayV3{ApiV3Key: testApiV3Key}
+	b, err := p.DecryptAEAD(testAEADNonce, testAEADAssoc, testAEADCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testAEADPlain {
+		t.Errorf("got %s", b)
+	}
+	if _, err = p.DecryptAEAD(testAEADNonce, "certificate", testAEADCipher); err == nil {
+		t.Error("expected error with wrong associated_data")
+	}
+	if _, err = p.DecryptAEAD("000000000000", testAEADAssoc, testAEADCipher); err == nil {
+		t.Error("expected error with wrong nonce")
+	}
+	if _, err = (&PayV3{ApiV3Key: "short"}).DecryptAEAD(testAEADNonce, testAEADAssoc, testAEADCipher); err == nil {
+		t.Error("expected error with invalid key length")
+	}
+}
+
+// newTestPlatform 生成测试用的平台证书并放入p的缓存
+func newTestPlatform(t *testing.T, p *PayV3) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.certs = map[string]*PayV3Cert{testPlatformSN: {SerialNo: testPlatformSN, ExpireTime: cert.NotAfter, Certificate: cert}}
+	p.certAt = time.Now()
+	return key
+}
+
+func signTestNotify(t *testing.T, key *rsa.PrivateKey, body []byte) http.Header {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "5K8264ILTKCH16CQ2502SI8ZNMTM67VS"
+	sum := sha256.Sum256([]byte(ts + "\n" + nonce + "\n" + string(body) + "\n"))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := make(http.Header)
+	h.Set("Wechatpay-Serial", testPlatformSN)
+	h.Set("Wechatpay-Timestamp", ts)
+	h.Set("Wechatpay-Nonce", nonce)
+	h.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+	return h
+}
+
+func TestPayV3DecryptNotify(t *testing.T) {
+	p := &PayV3{ApiV3Key: testApiV3Key}
+	key := newTestPlatform(t, p)
+	body := []byte(fmt.Sprintf(testNotifyFormat, testAEADCipher, testAEADAssoc, testAEADNonce))
+	h := signTestNotify(t, key, body)
+
+	tr, err := p.DecryptNotify(context.Background(), h, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.TransactionId != "1217752501201407033233368018" || tr.TradeState != "SUCCESS" || tr.Amount.Total != 100 || tr.Payer.OpenId != "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o" {
+		t.Errorf("unexpected transaction: %+v", tr)
+	}
+
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-3] = 'X'
+	if _, err = p.DecryptNotify(context.Background(), h, tampered); err != ErrPayV3Signature {
+		t.Errorf("expected ErrPayV3Signature for tampered body, got %v", err)
+	}
+
+	h.Set("Wechatpay-Timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if _, err = p.DecryptNotify(context.Background(), h, body); err == nil {
+		t.Error("expected error for expired timestamp")
+	}
+}
+
+type payV3RoundTrip func(*http.Request) (*http.Response, error)
+
+func (f payV3RoundTrip) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestPayV3UnknownSerialDownloadOnce(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	p := &PayV3{ApiV3Key: testApiV3Key, privateKey: key, Client: util.NewClient(util.WithClientTransport(payV3RoundTrip(func(r *http.Request) (*http.Response, error) {
+		n++
+		return &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})))}
+	platform := newTestPlatform(t, p)
+	body := []byte(`{}`)
+	h := signTestNotify(t, platform, body)
+	h.Set("Wechatpay-Serial", "FORGED")
+
+	for i := 0; i < 3; i++ {
+		if err = p.VerifySignature(context.Background(), h, body); err == nil {
+			t.Fatal("expected error for unknown serial")
+		}
+	}
+	if n != 1 {
+		t.Errorf("got %d certificate downloads, want 1", n)
+	}
+	if err != ErrPayV3Signature {
+		t.Errorf("expected ErrPayV3Signature, got %v", err)
+	}
+}
+
+func TestPayV3DoVerifyBeforeDecode(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"code_url":"weixin://wxpay/bizpayurl?pr=test"}`)
+	var h http.Header
+	p := &PayV3{privateKey: key, Client: util.NewClient(util.WithClientTransport(payV3RoundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: ioutil.NopCloser(strings.NewReader(string(body)))}, nil
+	})))}
+	ret := new(struct {
+		CodeUrl string `json:"code_url"`
+	})
+	if err = p.Do(context.Background(), http.MethodGet, "/v3/test", nil, ret); err != ErrPayV3NoApiV3Key {
+		t.Errorf("expected ErrPayV3NoApiV3Key, got %v", err)
+	}
+
+	p.ApiV3Key = testApiV3Key
+	h = signTestNotify(t, newTestPlatform(t, p), []byte(`{}`))
+	if err = p.Do(context.Background(), http.MethodGet, "/v3/test", nil, ret); err != ErrPayV3Signature {
+		t.Errorf("expected ErrPayV3Signature, got %v", err)
+	}
+	if ret.CodeUrl != "" {
+		t.Errorf("unverified body decoded into ret: %+v", ret)
+	}
+
+	h = signTestNotify(t, newTestPlatform(t, p), body)
+	if err = p.Do(context.Background(), http.MethodGet, "/v3/test", nil, ret); err != nil || ret.CodeUrl != "weixin://wxpay/bizpayurl?pr=test" {
+		t.Errorf("got %+v, %v", ret, err)
+	}
+}
+
+func TestPayV3ExpiringCertRefreshOnce(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int32
+	p := &PayV3{ApiV3Key: testApiV3Key, privateKey: key, Client: util.NewClient(util.WithClientTransport(payV3RoundTrip(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&n, 1)
+		return &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})))}
+	platform := newTestPlatform(t, p)
+	p.certs[testPlatformSN].ExpireTime = time.Now().Add(time.Hour)
+	body := []byte(`{}`)
+	h := signTestNotify(t, platform, body)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.VerifySignature(context.Background(), h, body); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 100 && atomic.LoadInt32(&n) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&n); got != 1 {
+		t.Errorf("got %d certificate downloads, want 1", got)
+	}
+}
+
+func TestPayV3DoMaxResponseBytes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &PayV3{SkipVerify: true, privateKey: key, Client: util.NewClient(util.WithClientMaxResponseBytes(16), util.WithClientTransport(payV3RoundTrip(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(`{"code_url":"` + strings.Repeat("x", 64) + `"}`))}, nil
+	})))}
+	if err = p.Do(context.Background(), http.MethodGet, "/v3/test", nil, nil); err != util.ErrResponseTooLarge {
+		t.Errorf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
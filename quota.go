@@ -0,0 +1,54 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// 接口调用次数接口
+const (
+	WXAPIQuotaGet     = WXAPI + "openapi/quota/get?access_token="
+	WXAPIClearQuota   = WXAPI + "clear_quota?access_token="
+	WXAPIClearQuotaV2 = WXAPI + "clear_quota/v2?appid=%s&appsecret=%s"
+)
+
+// QuotaInfo 接口调用次数信息
+type QuotaInfo struct {
+	WxErr
+	Quota struct {
+		DailyLimit int `json:"daily_limit"`
+		Used       int `json:"used"`
+		Remain     int `json:"remain"`
+	} `json:"quota"`
+	RateLimit struct {
+		CallCount     int `json:"call_count"`
+		RefreshSecond int `json:"refresh_second"`
+	} `json:"rate_limit"`
+}
+
+// GetQuotaCtx 查询接口当天的调用次数，cgiPath如/cgi-bin/message/custom/send
+func (s *Server) GetQuotaCtx(ctx context.Context, cgiPath string) (*QuotaInfo, error) {
+	if cgiPath == "" {
+		return nil, errors.New("wechat: empty cgi_path")
+	}
+	ret := new(QuotaInfo)
+	if err := s.postCtx(ctx, WXAPIQuotaGet, map[string]string{"cgi_path": cgiPath}, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ClearQuotaCtx 清空全部接口的调用次数，每月共10次机会，appId为空时使用Server.AppId
+func (s *Server) ClearQuotaCtx(ctx context.Context, appId string) error {
+	if appId == "" {
+		appId = s.AppId
+	}
+	return s.postCtx(ctx, WXAPIClearQuota, map[string]string{"appid": appId}, nil)
+}
+
+// ClearQuotaByAppSecretCtx 使用AppSecret清空调用次数，无需access_token，适用于获取token的次数也已用完的情况
+func (s *Server) ClearQuotaByAppSecretCtx(ctx context.Context) error {
+	return s.postCtx(ctx, fmt.Sprintf(WXAPIClearQuotaV2, url.QueryEscape(s.AppId), url.QueryEscape(s.Secret)), struct{}{}, nil)
+}
@@ -0,0 +1,54 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// replyResp 被动回复共用字段，to为用户openid，from为公众号原始id
+func replyResp(to, from, msgType string) wxResp {
+	return wxResp{
+		ToUserName:   CDATA(to),
+		FromUserName: CDATA(from),
+		CreateTime:   time.Now().Unix(),
+		MsgType:      CDATA(msgType),
+	}
+}
+
+// marshalReply 编码被动回复，回复结构体只含字符串及整数，编码不会出错
+func marshalReply(v interface{}) []byte {
+	b, _ := xml.Marshal(v)
+	return b
+}
+
+// ReplyText 文本被动回复xml
+func ReplyText(to, from, text string) []byte {
+	return marshalReply(&Text{replyResp(to, from, TypeText), content{CDATA(text)}})
+}
+
+// ReplyImage 图片被动回复xml
+func ReplyImage(to, from, mediaId string) []byte {
+	return marshalReply(&Image{replyResp(to, from, TypeImage), media{CDATA(mediaId)}})
+}
+
+// ReplyVoice 语音被动回复xml
+func ReplyVoice(to, from, mediaId string) []byte {
+	return marshalReply(&Voice{replyResp(to, from, TypeVoice), media{CDATA(mediaId)}})
+}
+
+// ReplyVideo 视频被动回复xml
+func ReplyVideo(to, from, mediaId, title, desc string) []byte {
+	return marshalReply(&Video{replyResp(to, from, TypeVideo), video{CDATA(mediaId), CDATA(title), CDATA(desc)}})
+}
+
+// ReplyMusic 音乐被动回复xml，thumbMediaId为缩略图的媒体id
+func ReplyMusic(to, from, title, desc, musicUrl, hqMusicUrl, thumbMediaId string) []byte {
+	return marshalReply(&Music{replyResp(to, from, TypeMusic), music{CDATA(title), CDATA(desc), CDATA(musicUrl), CDATA(hqMusicUrl), CDATA(thumbMediaId)}})
+}
+
+// ReplyNews 图文被动回复xml，公众号仅支持1条图文
+func ReplyNews(to, from string, arts []Article) []byte {
+	news := News{wxResp: replyResp(to, from, TypeNews), ArticleCount: len(arts)}
+	news.Articles.Item = arts
+	return marshalReply(&news)
+}
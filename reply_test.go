@@ -0,0 +1,34 @@
+package wechat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplyText(t *testing.T) {
+	b := string(ReplyText("oUser", "gh_123", "a<b>&c"))
+	for _, want := range []string{
+		"<xml>",
+		"<ToUserName><![CDATA[oUser]]></ToUserName>",
+		"<FromUserName><![CDATA[gh_123]]></FromUserName>",
+		"<MsgType><![CDATA[text]]></MsgType>",
+		"<Content><![CDATA[a<b>&c]]></Content>",
+		"<CreateTime>",
+	} {
+		if !strings.Contains(b, want) {
+			t.Errorf("ReplyText missing %s: %s", want, b)
+		}
+	}
+}
+
+func TestReplyMusicAndNews(t *testing.T) {
+	b := string(ReplyMusic("oUser", "gh_123", "t", "d", "http://m", "http://hq", "thumb"))
+	if !strings.Contains(b, "<Music><Title><![CDATA[t]]></Title><Description><![CDATA[d]]></Description><MusicUrl><![CDATA[http://m]]></MusicUrl><HQMusicUrl><![CDATA[http://hq]]></HQMusicUrl><ThumbMediaId><![CDATA[thumb]]></ThumbMediaId></Music>") {
+		t.Errorf("unexpected music reply: %s", b)
+	}
+
+	b = string(ReplyNews("oUser", "gh_123", []Article{NewArticle("title", "desc", "http://pic", "http://url")}))
+	if !strings.Contains(b, "<ArticleCount>1</ArticleCount><Articles><item><Title><![CDATA[title]]></Title>") {
+		t.Errorf("unexpected news reply: %s", b)
+	}
+}
@@ -0,0 +1,124 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/esap/wechat/util"
+)
+
+// withToken uri以access_token=结尾时追加access_token
+func (s *Server) withToken(ctx context.Context, uri string) (string, error) {
+	if !strings.HasSuffix(uri, "access_token=") {
+		return uri, nil
+	}
+	token, err := s.GetAccessTokenCtx(ctx)
+	if err != nil {
+		return "", err
+	}
+	return uri + token, nil
+}
+
+// checkWxErr ret带有WxErr时返回其中的错误
+func checkWxErr(ret interface{}) error {
+	if e, ok := ret.(interface{ Error() error }); ok {
+		return e.Error()
+	}
+	return nil
+}
+
+// getCtx 发送GET请求并解析json到ret，自动追加access_token并检查errcode
+func (s *Server) getCtx(ctx context.Context, uri string, ret interface{}) error {
+	uri, err := s.withToken(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if ret == nil {
+		ret = new(WxErr)
+	}
+	if err = util.GetJsonCtx(ctx, uri, ret); err != nil {
+		return err
+	}
+	return checkWxErr(ret)
+}
+
+// postCtx 发送json格式的POST请求并解析结果到ret，自动追加access_token并检查errcode，ret为nil时仅检查errcode
+func (s *Server) postCtx(ctx context.Context, uri string, obj, ret interface{}) error {
+	uri, err := s.withToken(ctx, uri)
+	if err != nil {
+		return err
+	}
+	body, err := util.PostJsonCtx(ctx, uri, obj)
+	if err != nil {
+		return err
+	}
+	if ret == nil {
+		ret = new(WxErr)
+	}
+	if err = json.Unmarshal(body, ret); err != nil {
+		return err
+	}
+	return checkWxErr(ret)
+}
+
+// BatchError 分批请求中某一批失败，Done为失败前已成功处理的数量
+type BatchError struct {
+	Done int
+	Err  error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch failed after %d done: %v", e.Done, e.Err)
+}
+
+// Unwrap 返回导致失败的错误，可配合util.IsErrCode使用
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// batch 将n个元素按size分批依次调用fn(start, end)，遇到错误立即停止并返回*BatchError
+func batch(n, size int, fn func(start, end int) error) error {
+	for i := 0; i < n; i += size {
+		end := i + size
+		if end > n {
+			end = n
+		}
+		if err := fn(i, end); err != nil {
+			return &BatchError{Done: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// uploadCtx 上传multipart表单并解析json结果到ret，自动追加access_token并检查errcode
+func (s *Server) uploadCtx(ctx context.Context, uri string, fields []util.MultipartFormField, ret interface{}) error {
+	uri, err := s.withToken(ctx, uri)
+	if err != nil {
+		return err
+	}
+	body, err := util.PostMultipartFormCtx(ctx, fields, uri)
+	if err != nil {
+		return err
+	}
+	if ret == nil {
+		ret = new(WxErr)
+	}
+	if err = json.Unmarshal(body, ret); err != nil {
+		return err
+	}
+	return checkWxErr(ret)
+}
+
+// fileField 文件表单字段，Content-Type按文件扩展名推断
+func fileField(fieldname, filename string, r io.Reader) util.MultipartFormField {
+	ct := mime.TypeByExtension(filepath.Ext(filename))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	return util.MultipartFormField{Fieldname: fieldname, Filename: filename, ContentType: ct, Reader: r}
+}
@@ -1,7 +1,9 @@
 package wechat
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"unicode/utf8"
 
@@ -29,6 +31,13 @@ func (s *Server) SendMsg(v interface{}) *WxErr {
 	return rst
 }
 
+// SendMsgCtx 发送消息，支持ctx取消，errcode非0时返回*util.WechatError
+func (s *Server) SendMsgCtx(ctx context.Context, v interface{}) error {
+	err := s.postCtx(ctx, s.MsgUrl, v, nil)
+	Printf("[*] 发送消息:%+v\n[*] 回执:%v", v, err)
+	return err
+}
+
 // SendText 发送客服text消息,过长时按500长度自动拆分
 func (s *Server) SendText(to, msg string) (e *WxErr) {
 	leng := utf8.RuneCountInString(msg)
@@ -44,6 +53,57 @@ func (s *Server) SendText(to, msg string) (e *WxErr) {
 	return
 }
 
+// SendTextCtx 发送客服text消息，支持ctx取消，过长时按500长度自动拆分，某段发送失败时立即返回
+func (s *Server) SendTextCtx(ctx context.Context, to, msg string) error {
+	leng := utf8.RuneCountInString(msg)
+	n := leng/500 + 1
+
+	if n == 1 {
+		return s.SendMsgCtx(ctx, s.NewText(to, msg))
+	}
+	for i := 0; i < n; i++ {
+		if err := s.SendMsgCtx(ctx, s.NewText(to, fmt.Sprintf("%s\n(%v/%v)", util.Substr(msg, i*500, (i+1)*500), i+1, n))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrEmptyMediaId 媒体消息缺少media_id
+var ErrEmptyMediaId = errors.New("wechat: media_id is empty")
+
+// SendImageCtx 发送客服Image消息，支持ctx取消
+func (s *Server) SendImageCtx(ctx context.Context, to, mediaId string) error {
+	if mediaId == "" {
+		return ErrEmptyMediaId
+	}
+	return s.SendMsgCtx(ctx, s.NewImage(to, mediaId))
+}
+
+// SendVoiceCtx 发送客服Voice消息，支持ctx取消
+func (s *Server) SendVoiceCtx(ctx context.Context, to, mediaId string) error {
+	if mediaId == "" {
+		return ErrEmptyMediaId
+	}
+	return s.SendMsgCtx(ctx, s.NewVoice(to, mediaId))
+}
+
+// SendVideoCtx 发送客服Video消息，支持ctx取消
+func (s *Server) SendVideoCtx(ctx context.Context, to, mediaId, title, desc string) error {
+	if mediaId == "" {
+		return ErrEmptyMediaId
+	}
+	return s.SendMsgCtx(ctx, s.NewVideo(to, mediaId, title, desc))
+}
+
+// SendMusicCtx 发送客服Music消息，支持ctx取消，mediaId为缩略图的media_id
+func (s *Server) SendMusicCtx(ctx context.Context, to, mediaId, title, desc, musicUrl, qhMusicUrl string) error {
+	if mediaId == "" {
+		return ErrEmptyMediaId
+	}
+	return s.SendMsgCtx(ctx, s.NewMusic(to, mediaId, title, desc, musicUrl, qhMusicUrl))
+}
+
 // SendImage 发送客服Image消息
 func (s *Server) SendImage(to string, mediaId string) *WxErr {
 	return s.SendMsg(s.NewImage(to, mediaId))
@@ -4,27 +4,32 @@
 package wechat
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/esap/wechat/util"
 )
 
 // WXAPI 订阅号，服务号，小程序接口，相关接口常量统一以此开头
 const (
-	WXAPI      = "https://api.weixin.qq.com/cgi-bin/"
-	WXAPIToken = WXAPI + "token?grant_type=client_credential&appid=%s&secret=%s"
-	WXAPIMsg   = WXAPI + "message/custom/send?access_token="
-	WXAPIJsapi = WXAPI + "get_jsapi_ticket?access_token="
+	WXAPI            = "https://api.weixin.qq.com/cgi-bin/"
+	WXAPIToken       = WXAPI + "token?grant_type=client_credential&appid=%s&secret=%s"
+	WXAPIStableToken = WXAPI + "stable_token"
+	WXAPIMsg         = WXAPI + "message/custom/send?access_token="
+	WXAPIJsapi       = WXAPI + "get_jsapi_ticket?access_token="
+)
+
+// WXAAPI 小程序专有接口，相关接口常量统一以此开头
+const (
+	WXAAPI = "https://api.weixin.qq.com/wxa/"
 )
 
 // CorpAPI 企业微信接口，相关接口常量统一以此开头
@@ -56,18 +61,30 @@ type WxConfig struct {
 	EncodingAESKey       string
 	AgentId              int
 	MchId                string
+	MchKey               string // 商户API密钥，用于微信支付v2签名
+	MchCertFile          string // 商户证书apiclient_cert.pem，用于退款等需要双向TLS的支付接口
+	MchKeyFile           string // 商户证书私钥apiclient_key.pem
 	AppName              string
 	AppType              int                                  // 0-公众号,小程序; 1-企业微信
 	ExternalTokenHandler func(string, ...string) *AccessToken // 外部token获取函数
 	DataFormat           string                               // 数据格式：JSON、XML
+	TokenStore           TokenStore                           // access_token存储，为nil时使用进程内存储
+	StableToken          bool                                 // 使用stable_token接口获取access_token，仅公众号、小程序有效
 }
 
 // Server 微信服务容器
 type Server struct {
-	AppId   string
-	MchId   string // 商户id，用于微信支付
-	AgentId int
-	Secret  string
+	AppId  string
+	MchId  string // 商户id，用于微信支付
+	MchKey string // 商户API密钥，用于微信支付v2签名
+
+	MchCertFile string // 商户证书，用于退款等需要双向TLS的支付接口
+	MchKeyFile  string // 商户证书私钥
+	payCertOnce sync.Once
+	payCert     *util.Client
+	payCertErr  error
+	AgentId     int
+	Secret      string
 
 	Token          string
 	EncodingAESKey string
@@ -85,8 +102,11 @@ type Server struct {
 	JsApi    string
 
 	Safe        int
-	accessToken *AccessToken
+	accessToken *AccessToken  // ExternalTokenHandler获取的token
+	tokens      *TokenManager // 本地获取token
 	ticket      *Ticket
+	cardTicket  *Ticket    // 卡券api_ticket
+	ticketMu    sync.Mutex // ticket读取锁
 	UserList    userList
 	DeptList    DeptList
 	TagList     TagList
@@ -94,6 +114,12 @@ type Server struct {
 	sync.Mutex  // accessToken读取锁
 
 	ExternalTokenHandler func(appId string, appName ...string) *AccessToken // 通过外部方法统一获取access token ,避免集群情况下token失效
+	TokenStore           TokenStore                                         // access_token存储，可用Redis等实现多实例共享
+	StableToken          bool                                               // 使用stable_token接口获取access_token
+
+	IpCacheTTL time.Duration // ApiDomainIpCtx、CallbackIpCtx结果的缓存时长，为0时不缓存
+	ipCache    map[string]ipCacheEntry
+	ipMu       sync.Mutex
 }
 
 func Set(wc *WxConfig) *Server {
@@ -102,12 +128,17 @@ func Set(wc *WxConfig) *Server {
 		Secret:               wc.Secret,
 		AgentId:              wc.AgentId,
 		MchId:                wc.MchId,
+		MchKey:               wc.MchKey,
+		MchCertFile:          wc.MchCertFile,
+		MchKeyFile:           wc.MchKeyFile,
 		AppName:              wc.AppName,
 		AppType:              wc.AppType,
 		Token:                wc.Token,
 		EncodingAESKey:       wc.EncodingAESKey,
 		ExternalTokenHandler: wc.ExternalTokenHandler,
 		DataFormat:           wc.DataFormat,
+		TokenStore:           wc.TokenStore,
+		StableToken:          wc.StableToken,
 	}
 }
 
@@ -134,7 +165,7 @@ func New(wc *WxConfig) *Server {
 		s.JsApi = WXAPIJsapi
 	}
 
-	err := s.getAccessToken()
+	_, err := s.GetAccessTokenCtx(context.Background())
 	if err != nil {
 		log.Println("getAccessToken err:", err)
 	}
@@ -226,11 +257,11 @@ func (s *Server) VerifyURL(w http.ResponseWriter, r *http.Request) (ctx *Context
 	if signature == "" {
 		signature = r.FormValue("msg_signature")
 	}
-	if s.EntMode && signature != util.SortSha1(s.Token, ctx.Timestamp, ctx.Nonce, echostr) {
-		log.Println("Signature验证错误!(企业微信)", s.Token, ctx.Timestamp, ctx.Nonce, echostr)
+	if s.EntMode && !VerifyMsgSignature(s.Token, signature, ctx.Timestamp, ctx.Nonce, echostr) {
+		log.Println("Signature验证错误!(企业微信)")
 		return
-	} else if !s.EntMode && signature != util.SortSha1(s.Token, ctx.Timestamp, ctx.Nonce) {
-		log.Println("Signature验证错误!(公众号)", util.SortSha1(s.Token, ctx.Timestamp, ctx.Nonce))
+	} else if !s.EntMode && !VerifySignature(s.Token, signature, ctx.Timestamp, ctx.Nonce) {
+		log.Println("Signature验证错误!(公众号)")
 		return
 	}
 
@@ -244,6 +275,7 @@ func (s *Server) VerifyURL(w http.ResponseWriter, r *http.Request) (ctx *Context
 		}
 	}
 
+	ctx.verified = true
 	if r.Method == "GET" {
 		Println("api echostr:", echostr)
 		w.Write([]byte(echostr))
@@ -263,25 +295,11 @@ func (s *Server) VerifyURL(w http.ResponseWriter, r *http.Request) (ctx *Context
 // DecryptMsg 解密微信消息,密文string->base64Dec->aesDec->去除头部随机字串
 // AES加密的buf由16个字节的随机字符串、4个字节的msg_len(网络字节序)、msg和$AppId组成
 func (s *Server) DecryptMsg(msg string) (string, error) {
-	aesMsg, err := base64.StdEncoding.DecodeString(msg)
-	if err != nil {
-		return "", err
-	}
-
-	buf, err := util.AesDecrypt(aesMsg, s.AesKey)
+	b, err := decryptMsg(s.AesKey, s.AppId, msg)
 	if err != nil {
 		return "", err
 	}
-
-	var msgLen int32
-	binary.Read(bytes.NewBuffer(buf[16:20]), binary.BigEndian, &msgLen)
-	if msgLen < 0 || msgLen > 1000000 {
-		return "", errors.New("AesKey is invalid")
-	}
-	if string(buf[20+msgLen:]) != s.AppId {
-		return "", errors.New("AppId is invalid")
-	}
-	return string(buf[20 : 20+msgLen]), nil
+	return string(b), nil
 }
 
 // wxRespEnc 加密回复体
@@ -296,25 +314,7 @@ type wxRespEnc struct {
 // EncryptMsg 加密普通回复(AES-CBC),打包成xml格式
 // AES加密的buf由16个字节的随机字符串、4个字节的msg_len(网络字节序)、msg和$AppId组成
 func (s *Server) EncryptMsg(msg []byte, timeStamp, nonce string) (re *wxRespEnc, err error) {
-	buf := new(bytes.Buffer)
-	err = binary.Write(buf, binary.BigEndian, int32(len(msg)))
-	if err != nil {
-		return
-	}
-	l := buf.Bytes()
-
-	rd := []byte(util.GetRandomString(16))
-
-	plain := bytes.Join([][]byte{rd, l, msg, []byte(s.AppId)}, nil)
-	ae, _ := util.AesEncrypt(plain, s.AesKey)
-	encMsg := base64.StdEncoding.EncodeToString(ae)
-	re = &wxRespEnc{
-		Encrypt:      CDATA(encMsg),
-		MsgSignature: CDATA(util.SortSha1(s.Token, timeStamp, nonce, encMsg)),
-		TimeStamp:    timeStamp,
-		Nonce:        CDATA(nonce),
-	}
-	return
+	return encryptMsg(s.AesKey, s.AppId, s.Token, msg, timeStamp, nonce)
 }
 
 // SetLog 设置log
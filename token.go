@@ -0,0 +1,168 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/esap/wechat/util"
+)
+
+// TokenRefreshAhead 默认在access_token过期前5分钟刷新
+var TokenRefreshAhead = 5 * time.Minute
+
+// TokenStore access_token存储，多实例部署时可用Redis等实现共享，避免各实例互相刷新导致token失效
+// 没有缓存的token时Get应返回空token及nil错误
+type TokenStore interface {
+	Get(ctx context.Context) (token string, expiry time.Time, err error)
+	Set(ctx context.Context, token string, expiry time.Time) error
+}
+
+// MemoryTokenStore 进程内的TokenStore，TokenManager的默认存储
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	token  string
+	expiry time.Time
+}
+
+// Get 读取token
+func (s *MemoryTokenStore) Get(ctx context.Context) (string, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token, s.expiry, nil
+}
+
+// Set 保存token
+func (s *MemoryTokenStore) Set(ctx context.Context, token string, expiry time.Time) error {
+	s.mu.Lock()
+	s.token, s.expiry = token, expiry
+	s.mu.Unlock()
+	return nil
+}
+
+// TokenManager access_token管理器，缓存token并在过期前自动刷新，并发调用时只有一个请求会访问token接口
+type TokenManager struct {
+	AppId        string
+	Secret       string
+	TokenUrl     string        // token接口，格式同WXAPIToken，为空时使用WXAPIToken
+	RefreshAhead time.Duration // 提前刷新时间，为0时使用TokenRefreshAhead
+	Store        TokenStore    // token存储，为nil时使用进程内存储
+	Stable       bool          // 使用stable_token接口，获取新token不会使旧token失效，适合多服务器部署
+
+	mu sync.Mutex
+}
+
+// NewTokenManager 创建access_token管理器，store为nil时使用MemoryTokenStore
+func NewTokenManager(appId, secret string, store ...TokenStore) *TokenManager {
+	m := &TokenManager{AppId: appId, Secret: secret}
+	if len(store) > 0 {
+		m.Store = store[0]
+	}
+	return m
+}
+
+// NewStableTokenManager 创建使用stable_token接口的access_token管理器
+func NewStableTokenManager(appId, secret string, store ...TokenStore) *TokenManager {
+	m := NewTokenManager(appId, secret, store...)
+	m.Stable = true
+	return m
+}
+
+// Token 返回有效的access_token，存储中的token过期或即将过期时重新获取并写回存储
+func (m *TokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	store := m.store()
+	token, expiry, err := store.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if token != "" && time.Now().Before(expiry.Add(-m.refreshAhead())) {
+		return token, nil
+	}
+	return m.refresh(ctx, false)
+}
+
+// ForceRefresh 跳过缓存重新获取access_token，stable模式下以force_refresh=true调用接口
+// 注意stable_token强制刷新有频率限制，且会使旧token失效
+func (m *TokenManager) ForceRefresh(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.refresh(ctx, true)
+}
+
+// refresh 获取token并写回存储，调用方需持有m.mu
+func (m *TokenManager) refresh(ctx context.Context, force bool) (string, error) {
+	var at *AccessToken
+	var err error
+	if m.Stable {
+		at, err = m.fetchStable(ctx, force)
+	} else {
+		at, err = m.fetch(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	expiry := time.Now().Add(time.Duration(at.ExpiresIn) * time.Second)
+	if err = m.store().Set(ctx, at.AccessToken, expiry); err != nil {
+		return "", err
+	}
+	return at.AccessToken, nil
+}
+
+// Invalidate 清除存储的access_token，下次调用Token时重新获取，可用于收到40001等错误后
+func (m *TokenManager) Invalidate(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.store().Set(ctx, "", time.Time{})
+}
+
+// store 返回token存储，未设置时创建MemoryTokenStore，调用方需持有m.mu
+func (m *TokenManager) store() TokenStore {
+	if m.Store == nil {
+		m.Store = new(MemoryTokenStore)
+	}
+	return m.Store
+}
+
+// fetch 请求token接口
+func (m *TokenManager) fetch(ctx context.Context) (*AccessToken, error) {
+	tokenUrl := m.TokenUrl
+	if tokenUrl == "" {
+		tokenUrl = WXAPIToken
+	}
+	at := new(AccessToken)
+	if err := util.GetJsonCtx(ctx, fmt.Sprintf(tokenUrl, m.AppId, m.Secret), at); err != nil {
+		return nil, err
+	}
+	if err := at.Error(); err != nil {
+		return nil, err
+	}
+	return at, nil
+}
+
+// fetchStable 请求stable_token接口
+func (m *TokenManager) fetchStable(ctx context.Context, force bool) (*AccessToken, error) {
+	req := map[string]interface{}{
+		"grant_type":    "client_credential",
+		"appid":         m.AppId,
+		"secret":        m.Secret,
+		"force_refresh": force,
+	}
+	at := new(AccessToken)
+	if err := util.PostJsonPtrCtx(ctx, WXAPIStableToken, req, at); err != nil {
+		return nil, err
+	}
+	if err := at.Error(); err != nil {
+		return nil, err
+	}
+	return at, nil
+}
+
+func (m *TokenManager) refreshAhead() time.Duration {
+	if m.RefreshAhead > 0 {
+		return m.RefreshAhead
+	}
+	return TokenRefreshAhead
+}
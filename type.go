@@ -5,6 +5,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strings"
+
+	"github.com/esap/wechat/util"
 )
 
 // Type io类型汇总
@@ -22,11 +24,19 @@ const (
 	TypeNews     = "news"
 	TypeMpNews   = "mpnews" // 仅企业微信可用
 	TypeEvent    = "event"  // 订阅或取消订阅
+
+	TypeLocation   = "location"   // 仅用于接收消息
+	TypeLink       = "link"       // 仅用于接收消息
+	TypeShortVideo = "shortvideo" // 仅用于接收消息
 )
 
 const (
 	EventSubscribe   = "subscribe"
 	EventUnsubscribe = "unsubscribe"
+	EventScan        = "SCAN"     // 已关注用户扫描带参数二维码
+	EventLocation    = "LOCATION" // 上报地理位置
+	EventClick       = "CLICK"    // 点击菜单拉取消息
+	EventView        = "VIEW"     // 点击菜单跳转链接
 )
 
 // WxErr 通用错误
@@ -35,9 +45,10 @@ type WxErr struct {
 	ErrMsg  string
 }
 
+// Error 返回*util.WechatError，可用util.IsErrCode判断具体错误码
 func (w *WxErr) Error() error {
 	if w.ErrCode != 0 {
-		return fmt.Errorf("err: errcode=%v , errmsg=%v", w.ErrCode, w.ErrMsg)
+		return &util.WechatError{Code: w.ErrCode, Msg: w.ErrMsg}
 	}
 	return nil
 }
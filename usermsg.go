@@ -1,6 +1,8 @@
 package wechat
 
 import (
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
 )
 
@@ -52,3 +54,30 @@ type (
 		AgentType  string
 	}
 )
+
+// ParseMsg 解析明文回调消息，支持xml及json格式
+func ParseMsg(body []byte) (*WxMsg, error) {
+	msg := new(WxMsg)
+	var err error
+	if b := bytes.TrimSpace(body); len(b) > 0 && b[0] == '{' {
+		err = json.Unmarshal(b, msg)
+	} else {
+		err = xml.Unmarshal(b, msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ParseCallback 解析回调请求体，安全模式下先校验msg_signature并解密
+func (s *Server) ParseCallback(timestamp, nonce, msgSignature string, body []byte) (*WxMsg, error) {
+	if !s.SafeMode {
+		return ParseMsg(body)
+	}
+	b, err := DecryptCallbackMsg(s.Token, s.EncodingAESKey, s.AppId, timestamp, nonce, msgSignature, body)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMsg(b)
+}
@@ -0,0 +1,840 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client http客户端，持有独立的超时、代理等配置，可用于同时对接多个微信环境(如正式与沙箱、多商户)
+type Client struct {
+	Timeout    time.Duration                         // 请求超时，为0时使用全局TimeOut
+	Proxy      func(*http.Request) (*url.URL, error) // 代理，为nil时使用全局Proxy
+	TLSConfig  *tls.Config                           // TLS配置，如微信支付退款所需的商户证书，重新赋值后重建Transport
+	NoRedirect bool                                  // 不自动跟随重定向，可通过GetResponse读取Location
+	Jar        http.CookieJar                        // Cookie容器，为nil时不保存cookie
+	HTTPClient *http.Client                          // 自定义http.Client，为nil时按上述配置自动创建，其Transport同样经SetTransport包装
+
+	userAgent *string // 为nil时使用全局UserAgent
+	maxBytes  *int64  // 为nil时使用全局MaxResponseBytes
+
+	mu        sync.RWMutex
+	limiter   *RateLimiter
+	logger    LogFunc
+	transport http.RoundTripper // 自定义RoundTripper，可用于测试时返回预置响应
+
+	base    *http.Transport // 按TLSConfig创建的内置Transport
+	baseTLS *tls.Config     // 创建base时使用的TLSConfig
+}
+
+// ClientOption Client配置项，用于NewClient()
+type ClientOption func(*Client)
+
+// WithClientTimeout 设置Client的请求超时
+func WithClientTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.Timeout = d
+	}
+}
+
+// WithClientProxy 设置Client的代理
+func WithClientProxy(p func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(c *Client) {
+		c.Proxy = p
+	}
+}
+
+// WithClientProxyURL 设置Client的固定代理地址
+func WithClientProxyURL(u *url.URL) ClientOption {
+	return func(c *Client) {
+		c.Proxy = http.ProxyURL(u)
+	}
+}
+
+// WithClientTLSConfig 设置Client的TLS配置
+func WithClientTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.TLSConfig = cfg
+	}
+}
+
+// WithClientCertificate 设置Client的客户端证书，用于双向TLS
+func WithClientCertificate(certs ...tls.Certificate) ClientOption {
+	return func(c *Client) {
+		if c.TLSConfig == nil {
+			c.TLSConfig = new(tls.Config)
+		}
+		c.TLSConfig.Certificates = append(c.TLSConfig.Certificates, certs...)
+	}
+}
+
+// WithClientRootCAs 设置Client信任的根证书，可用于证书锁定
+func WithClientRootCAs(pool *x509.CertPool) ClientOption {
+	return func(c *Client) {
+		if c.TLSConfig == nil {
+			c.TLSConfig = new(tls.Config)
+		}
+		c.TLSConfig.RootCAs = pool
+	}
+}
+
+// NewTLSConfig 从PEM格式的证书、私钥文件创建TLS配置，caFile为空时使用系统根证书
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", caFile)
+		}
+	}
+	return cfg, nil
+}
+
+// WithClientUserAgent 设置Client的User-Agent，传入空字符串则不发送
+func WithClientUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = &ua
+	}
+}
+
+// WithClientMaxResponseBytes 设置Client的响应体大小上限，传入0则不限制
+func WithClientMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxBytes = &n
+	}
+}
+
+// WithClientRateLimit 设置Client的限流，rps为每秒请求数，burst为允许的突发请求数
+func WithClientRateLimit(rps float64, burst int) ClientOption {
+	return func(c *Client) {
+		c.SetRateLimit(rps, burst)
+	}
+}
+
+// WithClientNoRedirect 设置Client不自动跟随重定向
+func WithClientNoRedirect() ClientOption {
+	return func(c *Client) {
+		c.NoRedirect = true
+	}
+}
+
+// WithClientCookieJar 设置Client的Cookie容器，用于依赖会话的接口
+func WithClientCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *Client) {
+		c.Jar = jar
+	}
+}
+
+// WithClientTransport 设置Client的RoundTripper，设置后Proxy、TLSConfig不再生效
+func WithClientTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.SetTransport(rt)
+	}
+}
+
+// WithHTTPClient 使用自定义的http.Client
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.HTTPClient = hc
+	}
+}
+
+// NewClient 创建Client
+func NewClient(opts ...ClientOption) *Client {
+	c := new(Client)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// DefaultClient 默认Client，包级请求函数均通过它发送，使用全局TimeOut、Proxy配置
+var DefaultClient = NewClient()
+
+// httpClient 返回本次请求使用的http.Client，超时由doRequest通过ctx控制
+// NoRedirect、Jar每次请求时读取，内置Transport在TLSConfig重新赋值后重建；
+// 设置了HTTPClient时复制一份并包装其Transport，使SetTransport依然生效
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		hc := *c.HTTPClient
+		hc.Transport = &clientTransport{c, c.HTTPClient.Transport}
+		return &hc
+	}
+	hc := &http.Client{Transport: &clientTransport{c, c.baseTransport()}, Jar: c.Jar}
+	if c.NoRedirect {
+		hc.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return hc
+}
+
+// baseTransport 返回复用的内置Transport，TLSConfig变化时重新创建
+func (c *Client) baseTransport() *http.Transport {
+	c.mu.RLock()
+	tr, cfg := c.base, c.baseTLS
+	c.mu.RUnlock()
+	if tr != nil && cfg == c.TLSConfig {
+		return tr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.base != nil && c.baseTLS == c.TLSConfig {
+		return c.base
+	}
+	if c.base != nil {
+		c.base.CloseIdleConnections()
+	}
+	tr = http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = c.proxy
+	if c.TLSConfig != nil {
+		tr.TLSClientConfig = c.TLSConfig
+	}
+	c.base, c.baseTLS = tr, c.TLSConfig
+	return tr
+}
+
+// SetTransport 设置Client的RoundTripper，传入nil则恢复默认Transport
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.mu.Lock()
+	c.transport = rt
+	c.mu.Unlock()
+}
+
+// clientTransport 优先使用Client上设置的RoundTripper，base为nil时使用http.DefaultTransport
+type clientTransport struct {
+	c    *Client
+	base http.RoundTripper
+}
+
+func (t *clientTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.c.mu.RLock()
+	rt := t.c.transport
+	t.c.mu.RUnlock()
+	if rt != nil {
+		return rt.RoundTrip(r)
+	}
+	if t.base == nil {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+	return t.base.RoundTrip(r)
+}
+
+// proxy 依次使用Client代理、全局代理、环境变量代理
+func (c *Client) proxy(r *http.Request) (*url.URL, error) {
+	if c.Proxy != nil {
+		return c.Proxy(r)
+	}
+	if p := getProxy(); p != nil {
+		return p(r)
+	}
+	return http.ProxyFromEnvironment(r)
+}
+
+// SetRateLimit 设置Client的限流，发送请求前等待令牌，rps<=0时取消限流
+func (c *Client) SetRateLimit(rps float64, burst int) {
+	var l *RateLimiter
+	if rps > 0 {
+		l = NewRateLimiter(rps, burst)
+	}
+	c.mu.Lock()
+	c.limiter = l
+	c.mu.Unlock()
+}
+
+// SetLogger 设置Client的请求日志回调，传入nil则关闭
+func (c *Client) SetLogger(fn LogFunc) {
+	c.mu.Lock()
+	c.logger = fn
+	c.mu.Unlock()
+}
+
+// getLogger 返回请求日志回调
+func (c *Client) getLogger() LogFunc {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logger
+}
+
+// wait 等待限流令牌
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.RLock()
+	l := c.limiter
+	c.mu.RUnlock()
+	if l == nil {
+		return nil
+	}
+	return l.Wait(ctx)
+}
+
+// getUserAgent 返回Client的User-Agent，未设置时使用全局UserAgent
+func (c *Client) getUserAgent() string {
+	if c.userAgent != nil {
+		return *c.userAgent
+	}
+	return getUserAgent()
+}
+
+// maxResponseBytes 返回Client的响应体大小上限，未设置时使用全局MaxResponseBytes
+func (c *Client) maxResponseBytes() int64 {
+	if c.maxBytes != nil {
+		return *c.maxBytes
+	}
+	return getMaxResponseBytes()
+}
+
+// timeout 返回Client超时，未设置时使用全局TimeOut
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return getTimeOut()
+}
+
+// GetJson 发送GET请求解析json
+func (c *Client) GetJson(uri string, v interface{}) error {
+	return c.GetJsonCtx(context.Background(), uri, v)
+}
+
+// GetJsonCtx 发送GET请求解析json，支持ctx取消
+func (c *Client) GetJsonCtx(ctx context.Context, uri string, v interface{}, opts ...RequestOption) error {
+	r, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	return decodeJson(c.limitBody(r.Body), v, c.newRequestOptions(opts))
+}
+
+// GetXml 发送GET请求并解析xml
+func (c *Client) GetXml(uri string, v interface{}) error {
+	return c.GetXmlCtx(context.Background(), uri, v)
+}
+
+// GetXmlCtx 发送GET请求并解析xml，支持ctx取消
+func (c *Client) GetXmlCtx(ctx context.Context, uri string, v interface{}, opts ...RequestOption) error {
+	r, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	return xml.NewDecoder(c.limitBody(r.Body)).Decode(v)
+}
+
+// GetBody 发送GET请求，返回body字节
+func (c *Client) GetBody(uri string) ([]byte, error) {
+	return c.GetBodyCtx(context.Background(), uri)
+}
+
+// GetBodyCtx 发送GET请求，返回body字节，支持ctx取消
+func (c *Client) GetBodyCtx(ctx context.Context, uri string, opts ...RequestOption) ([]byte, error) {
+	b, _, err := c.GetBodyWithResponseCtx(ctx, uri, opts...)
+	return b, err
+}
+
+// GetBodyWithResponse 发送GET请求，返回body字节及响应头，可用于读取Content-Type、Content-Disposition
+func (c *Client) GetBodyWithResponse(uri string) ([]byte, http.Header, error) {
+	return c.GetBodyWithResponseCtx(context.Background(), uri)
+}
+
+// GetBodyWithResponseCtx 发送GET请求，返回body字节及响应头，支持ctx取消
+func (c *Client) GetBodyWithResponseCtx(ctx context.Context, uri string, opts ...RequestOption) ([]byte, http.Header, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.MethodGet, uri); err != nil {
+		return nil, resp.Header, err
+	}
+	if o := c.newRequestOptions(opts); o.checkMedia {
+		if err = checkMediaResponse(resp, o.mediaType); err != nil {
+			return nil, resp.Header, err
+		}
+	}
+	b, err := ioutil.ReadAll(c.limitBody(resp.Body))
+	return b, resp.Header, err
+}
+
+// GetBodyWithHeaders 发送带自定义请求头的GET请求，返回body字节
+func (c *Client) GetBodyWithHeaders(uri string, headers http.Header) ([]byte, error) {
+	return c.GetBodyCtx(context.Background(), uri, WithHeaders(headers))
+}
+
+// GetResponse 发送GET请求，返回原始响应，不检查状态码，调用方负责关闭resp.Body
+// 配合NoRedirect使用可获取重定向目标resp.Header.Get("Location")
+func (c *Client) GetResponse(uri string) (*http.Response, error) {
+	return c.GetResponseCtx(context.Background(), uri)
+}
+
+// GetResponseCtx 发送GET请求，返回原始响应，支持ctx取消，调用方负责关闭resp.Body
+func (c *Client) GetResponseCtx(ctx context.Context, uri string, opts ...RequestOption) (*http.Response, error) {
+	return c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+}
+
+// DoCtx 按method发送请求，返回原始响应，不检查状态码，调用方负责关闭resp.Body，需限制大小时使用LimitBody读取
+// 用于需要自行处理请求头或响应头的接口，如微信支付v3
+func (c *Client) DoCtx(ctx context.Context, method, uri, contentType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return c.doRequest(ctx, method, uri, contentType, body, opts...)
+}
+
+// GetRawBody 发送GET请求，返回未读取的响应体，用于流式转发大文件
+// 调用方负责关闭返回的io.ReadCloser
+func (c *Client) GetRawBody(uri string) (io.ReadCloser, error) {
+	return c.GetRawBodyCtx(context.Background(), uri)
+}
+
+// GetRawBodyCtx 发送GET请求，返回未读取的响应体，支持ctx取消
+// 调用方负责关闭返回的io.ReadCloser，超时设置对读取body的过程同样有效
+func (c *Client) GetRawBodyCtx(ctx context.Context, uri string, opts ...RequestOption) (io.ReadCloser, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkStatus(resp, http.MethodGet, uri); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// PostJson 发送Json格式的POST请求
+func (c *Client) PostJson(uri string, obj interface{}) ([]byte, error) {
+	return c.PostJsonCtx(context.Background(), uri, obj)
+}
+
+// PostJsonCtx 发送Json格式的POST请求，支持ctx取消
+func (c *Client) PostJsonCtx(ctx context.Context, uri string, obj interface{}, opts ...RequestOption) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(obj)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doRequest(ctx, http.MethodPost, uri, "application/json;charset=utf-8", buf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.MethodPost, uri); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(c.limitBody(resp.Body))
+}
+
+// PostJsonPtr 发送Json格式的POST请求并解析结果到result指针
+func (c *Client) PostJsonPtr(uri string, obj interface{}, result interface{}, contentType ...string) (err error) {
+	ct := "application/json;charset=utf-8"
+	if len(contentType) > 0 {
+		ct = strings.Join(contentType, ";")
+	}
+	return c.sendJsonPtr(context.Background(), http.MethodPost, uri, obj, result, ct)
+}
+
+// PostJsonPtrCtx 发送Json格式的POST请求并解析结果到result指针，支持ctx取消
+func (c *Client) PostJsonPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) (err error) {
+	return c.sendJsonPtr(ctx, http.MethodPost, uri, obj, result, "application/json;charset=utf-8", opts...)
+}
+
+// PutJsonPtr 发送Json格式的PUT请求并解析结果到result指针
+func (c *Client) PutJsonPtr(uri string, obj interface{}, result interface{}) error {
+	return c.PutJsonPtrCtx(context.Background(), uri, obj, result)
+}
+
+// PutJsonPtrCtx 发送Json格式的PUT请求并解析结果到result指针，支持ctx取消
+func (c *Client) PutJsonPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) error {
+	return c.sendJsonPtr(ctx, http.MethodPut, uri, obj, result, "application/json;charset=utf-8", opts...)
+}
+
+// PatchJsonPtr 发送Json格式的PATCH请求并解析结果到result指针
+func (c *Client) PatchJsonPtr(uri string, obj interface{}, result interface{}) error {
+	return c.PatchJsonPtrCtx(context.Background(), uri, obj, result)
+}
+
+// PatchJsonPtrCtx 发送Json格式的PATCH请求并解析结果到result指针，支持ctx取消
+func (c *Client) PatchJsonPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) error {
+	return c.sendJsonPtr(ctx, http.MethodPatch, uri, obj, result, "application/json;charset=utf-8", opts...)
+}
+
+// DeleteJson 发送DELETE请求并解析结果到result指针，obj为nil时不带请求体
+func (c *Client) DeleteJson(uri string, obj interface{}, result interface{}) error {
+	return c.DeleteJsonCtx(context.Background(), uri, obj, result)
+}
+
+// DeleteJsonCtx 发送DELETE请求并解析结果到result指针，支持ctx取消
+func (c *Client) DeleteJsonCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) error {
+	return c.sendJsonPtr(ctx, http.MethodDelete, uri, obj, result, "application/json;charset=utf-8", opts...)
+}
+
+// sendJsonPtr 按method发送Json请求并解析结果到result指针，obj为nil时不带请求体，result为nil时不解析
+func (c *Client) sendJsonPtr(ctx context.Context, method, uri string, obj interface{}, result interface{}, ct string, opts ...RequestOption) (err error) {
+	var body io.Reader
+	if obj != nil {
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		if err = enc.Encode(obj); err != nil {
+			return
+		}
+		body = buf
+	} else {
+		ct = ""
+	}
+	return c.sendPtr(ctx, method, uri, ct, body, result, opts...)
+}
+
+// sendPtr 发送请求，检查状态码后解析json结果到result指针，result为nil时不解析
+func (c *Client) sendPtr(ctx context.Context, method, uri, ct string, body io.Reader, result interface{}, opts ...RequestOption) error {
+	resp, err := c.doRequest(ctx, method, uri, ct, body, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, method, uri); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return decodeJson(c.limitBody(resp.Body), result, c.newRequestOptions(opts))
+}
+
+// PostForm 发送application/x-www-form-urlencoded格式的POST请求并解析json结果到result指针
+func (c *Client) PostForm(uri string, values url.Values, result interface{}) error {
+	return c.PostFormCtx(context.Background(), uri, values, result)
+}
+
+// PostFormCtx 发送表单格式的POST请求并解析json结果到result指针，支持ctx取消
+func (c *Client) PostFormCtx(ctx context.Context, uri string, values url.Values, result interface{}, opts ...RequestOption) error {
+	return c.sendPtr(ctx, http.MethodPost, uri, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()), result, opts...)
+}
+
+// PostXmlPtr 发送Xml格式的POST请求并解析结果到result指针
+func (c *Client) PostXmlPtr(uri string, obj interface{}, result interface{}) (err error) {
+	return c.PostXmlPtrCtx(context.Background(), uri, obj, result)
+}
+
+// PostXmlPtrCtx 发送Xml格式的POST请求并解析结果到result指针，支持ctx取消
+func (c *Client) PostXmlPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) (err error) {
+	buf := new(bytes.Buffer)
+	enc := xml.NewEncoder(buf)
+	err = enc.Encode(obj)
+	if err != nil {
+		return
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodPost, uri, "application/xml;charset=utf-8", buf, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.MethodPost, uri); err != nil {
+		return err
+	}
+	return xml.NewDecoder(c.limitBody(resp.Body)).Decode(result)
+}
+
+// PostFileBytes 上传文件
+func (c *Client) PostFileBytes(fieldname string, filename string, contentType string, data []byte, uri string) ([]byte, error) {
+	return c.PostFileBytesCtx(context.Background(), fieldname, filename, contentType, data, uri)
+}
+
+// PostFileBytesCtx 上传文件，支持ctx取消
+func (c *Client) PostFileBytesCtx(ctx context.Context, fieldname string, filename string, contentType string, data []byte, uri string, opts ...RequestOption) ([]byte, error) {
+	fields := []MultipartFormField{
+		{
+			Fieldname:   fieldname,
+			Value:       data,
+			ContentType: contentType,
+			Filename:    filename,
+		},
+	}
+	return c.PostMultipartFormCtx(ctx, fields, uri, opts...)
+}
+
+// GetFile 下载文件，filename为空时按响应头在当前目录生成文件名，见SaveFile
+func (c *Client) GetFile(filename, uri string) error {
+	return c.GetFileCtx(context.Background(), filename, uri)
+}
+
+// GetFileCtx 下载文件，支持ctx取消，下载失败时删除已创建的文件
+func (c *Client) GetFileCtx(ctx context.Context, filename, uri string, opts ...RequestOption) error {
+	if filename == "" {
+		_, err := c.SaveFileCtx(ctx, "", uri, opts...)
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = c.GetToWriterCtx(ctx, file, uri, opts...)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(filename)
+	}
+	return err
+}
+
+// SaveFile 下载文件到dir目录，文件名取自Content-Disposition，没有时随机生成并按Content-Type补全扩展名
+// 返回保存的文件路径
+func (c *Client) SaveFile(dir, uri string) (string, error) {
+	return c.SaveFileCtx(context.Background(), dir, uri)
+}
+
+// SaveFileCtx 下载文件到dir目录，支持ctx取消，下载失败时删除已创建的文件
+func (c *Client) SaveFileCtx(ctx context.Context, dir, uri string, opts ...RequestOption) (string, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.MethodGet, uri); err != nil {
+		return "", err
+	}
+	if o := c.newRequestOptions(opts); o.checkMedia {
+		if err = checkMediaResponse(resp, o.mediaType); err != nil {
+			return "", err
+		}
+	}
+	filename := filepath.Join(dir, FileNameFromHeader(resp.Header))
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, resp.Body)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(filename)
+		return "", err
+	}
+	return filename, nil
+}
+
+// GetFileResume 断点续传下载文件，已存在的部分通过Range请求跳过
+func (c *Client) GetFileResume(filename, uri string) error {
+	return c.GetFileResumeCtx(context.Background(), filename, uri)
+}
+
+// GetFileResumeCtx 断点续传下载文件，支持ctx取消
+// 服务端不支持Range(返回200)时清空文件重新下载，Content-Range与本地文件大小不符时返回错误
+func (c *Client) GetFileResumeCtx(ctx context.Context, filename, uri string, opts ...RequestOption) error {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if offset > 0 {
+		h := http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+		opts = append(opts, WithHeaders(h))
+	}
+	resp, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, _, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != offset {
+			return fmt.Errorf("content-range %q does not start at offset %d", resp.Header.Get("Content-Range"), offset)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 仅当服务端返回的总大小与本地文件一致时视为已下载完整
+		_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if total != offset {
+			return fmt.Errorf("range not satisfiable: local size %d, remote size %d", offset, total)
+		}
+		return nil
+	case http.StatusOK:
+		if err = file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	default:
+		return checkStatus(resp, http.MethodGet, uri)
+	}
+	_, err = io.Copy(file, resp.Body)
+	return err
+}
+
+// parseContentRange 解析"bytes start-end/total"或"bytes */total"，start、end、total未知时为-1
+func parseContentRange(v string) (start, end, total int64, err error) {
+	start, end, total = -1, -1, -1
+	spec := strings.TrimPrefix(v, "bytes ")
+	i := strings.IndexByte(spec, '/')
+	if spec == v || i < 0 {
+		return start, end, total, fmt.Errorf("invalid content-range %q", v)
+	}
+	if t := spec[i+1:]; t != "*" {
+		if total, err = strconv.ParseInt(t, 10, 64); err != nil || total < 0 {
+			return -1, -1, -1, fmt.Errorf("invalid content-range %q", v)
+		}
+	}
+	if r := spec[:i]; r != "*" {
+		j := strings.IndexByte(r, '-')
+		if j < 0 {
+			return -1, -1, -1, fmt.Errorf("invalid content-range %q", v)
+		}
+		start, err = strconv.ParseInt(r[:j], 10, 64)
+		if err == nil {
+			end, err = strconv.ParseInt(r[j+1:], 10, 64)
+		}
+		if err != nil || start < 0 || end < start {
+			return -1, -1, -1, fmt.Errorf("invalid content-range %q", v)
+		}
+	}
+	return start, end, total, nil
+}
+
+// GetToWriter 发送GET请求，将响应体写入w，返回写入的字节数
+func (c *Client) GetToWriter(w io.Writer, uri string) (int64, error) {
+	return c.GetToWriterCtx(context.Background(), w, uri)
+}
+
+// GetToWriterCtx 发送GET请求，将响应体写入w，返回写入的字节数，支持ctx取消
+func (c *Client) GetToWriterCtx(ctx context.Context, w io.Writer, uri string, opts ...RequestOption) (int64, error) {
+	resp, err := c.doRequest(ctx, http.MethodGet, uri, "", nil, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.MethodGet, uri); err != nil {
+		return 0, err
+	}
+	if o := c.newRequestOptions(opts); o.checkMedia {
+		if err = checkMediaResponse(resp, o.mediaType); err != nil {
+			return 0, err
+		}
+	}
+	return io.Copy(w, resp.Body)
+}
+
+// PostMultipartForm 上传文件或其他表单数据
+func (c *Client) PostMultipartForm(fields []MultipartFormField, uri string) (respBody []byte, err error) {
+	return c.PostMultipartFormCtx(context.Background(), fields, uri)
+}
+
+// PostMultipartFormCtx 上传文件或其他表单数据，支持ctx取消
+// 若有字段设置了Reader，则通过io.Pipe边读边传，不在内存中缓存整个请求体(此时不支持失败重试)
+func (c *Client) PostMultipartFormCtx(ctx context.Context, fields []MultipartFormField, uri string, opts ...RequestOption) (respBody []byte, err error) {
+	var body io.Reader
+	var contentType string
+	if hasReaderField(fields) {
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		bodyWriter := multipart.NewWriter(pw)
+		go func() {
+			pw.CloseWithError(writeMultipartFields(bodyWriter, fields))
+		}()
+		body, contentType = pr, bodyWriter.FormDataContentType()
+	} else {
+		bodyBuf := &bytes.Buffer{}
+		bodyWriter := multipart.NewWriter(bodyBuf)
+		if err = writeMultipartFields(bodyWriter, fields); err != nil {
+			return
+		}
+		body, contentType = bodyBuf, bodyWriter.FormDataContentType()
+	}
+
+	resp, e := c.doRequest(ctx, http.MethodPost, uri, contentType, body, opts...)
+	if e != nil {
+		err = e
+		return
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, http.MethodPost, uri); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(c.limitBody(resp.Body))
+}
+
+func hasReaderField(fields []MultipartFormField) bool {
+	for _, field := range fields {
+		if field.Reader != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeMultipartFields 写入所有表单字段并关闭bodyWriter
+func writeMultipartFields(bodyWriter *multipart.Writer, fields []MultipartFormField) error {
+	for _, field := range fields {
+		var valueReader io.Reader = bytes.NewReader(field.Value)
+		length := int64(len(field.Value))
+		if field.Reader != nil {
+			valueReader, length = field.Reader, field.Length
+		}
+
+		h := make(textproto.MIMEHeader)
+		if field.Filename == "" {
+			// 普通表单字段，如永久视频素材的description
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, field.Fieldname))
+		} else {
+			disposition := fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field.Fieldname, field.Filename)
+			if length > 0 {
+				disposition += fmt.Sprintf("; filelength=%d", length)
+			}
+			h.Set("Content-Disposition", disposition)
+			h.Set("Content-Type", field.ContentType)
+		}
+		partWriter, err := bodyWriter.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(partWriter, valueReader); err != nil {
+			return err
+		}
+	}
+	return bodyWriter.Close()
+}
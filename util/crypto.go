@@ -4,24 +4,42 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
-	"io"
+	"errors"
 )
 
+// ErrPadding 密文长度或PKCS#7填充无效，通常为密钥或iv错误
+var ErrPadding = errors.New("crypto: invalid ciphertext or padding")
+
 // AesDecrypt AES-CBC解密,PKCS#7,传入密文和密钥，[]byte
+// iv取密钥前16字节，与微信消息加解密约定一致
 func AesDecrypt(src, key []byte) (dst []byte, err error) {
+	if len(key) < aes.BlockSize {
+		return nil, aes.KeySizeError(len(key))
+	}
+	return AesCBCDecrypt(src, key, key[:aes.BlockSize])
+}
+
+// AesCBCDecrypt AES-CBC解密，使用指定iv并校验PKCS#7填充
+func AesCBCDecrypt(src, key, iv []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, err
+	if len(iv) != aes.BlockSize || len(src) == 0 || len(src)%aes.BlockSize != 0 {
+		return nil, ErrPadding
 	}
-	dst = make([]byte, len(src))
+	dst := make([]byte, len(src))
 	cipher.NewCBCDecrypter(block, iv).CryptBlocks(dst, src)
-
-	return PKCS7UnPad(dst), nil
+	n := int(dst[len(dst)-1])
+	if n == 0 || n > len(dst) || n > 32 {
+		return nil, ErrPadding
+	}
+	for _, b := range dst[len(dst)-n:] {
+		if int(b) != n {
+			return nil, ErrPadding
+		}
+	}
+	return dst[:len(dst)-n], nil
 }
 
 // PKCS7UnPad PKSC#7解包
@@ -32,24 +50,16 @@ func PKCS7UnPad(msg []byte) []byte {
 }
 
 // AesEncrypt AES-CBC加密+PKCS#7打包，传入明文和密钥
+// 按密钥长度填充，iv取密钥前16字节，与微信消息加解密约定一致
 func AesEncrypt(src []byte, key []byte) ([]byte, error) {
-	k := len(key)
-	if len(src)%k != 0 {
-		src = PKCS7Pad(src, k)
-	}
-
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, err
-	}
+	src = PKCS7Pad(src, len(key))
 
 	dst := make([]byte, len(src))
-	cipher.NewCBCEncrypter(block, iv).CryptBlocks(dst, src)
+	cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(dst, src)
 
 	return dst, nil
 }
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// mediaExts 微信常见媒体类型的扩展名，优先于系统mime表
+var mediaExts = map[string]string{
+	"image/jpeg":  ".jpg",
+	"image/jpg":   ".jpg",
+	"image/png":   ".png",
+	"image/gif":   ".gif",
+	"image/bmp":   ".bmp",
+	"audio/amr":   ".amr",
+	"audio/mpeg":  ".mp3",
+	"audio/mp3":   ".mp3",
+	"audio/speex": ".speex",
+	"video/mp4":   ".mp4",
+	"text/plain":  ".txt",
+}
+
+// ExtByContentType 根据Content-Type返回文件扩展名(含"."),未知类型返回空字符串
+func ExtByContentType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := mediaExts[mt]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mt); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// FileNameFromHeader 根据响应头生成文件名，优先使用Content-Disposition中的filename，
+// 没有时随机生成并按Content-Type补全扩展名
+func FileNameFromHeader(h http.Header) string {
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		if name := filepath.Base(strings.Trim(params["filename"], `"`)); name != "." && name != ".." && name != "/" && name != "" {
+			return name
+		}
+	}
+	return GetRandomString(16) + ExtByContentType(h.Get("Content-Type"))
+}
@@ -1,232 +1,741 @@
 package util
 
 import (
-	"bytes"
-	"encoding/json"
-	"encoding/xml"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
+	"math/rand"
 	"net/http"
-	"net/textproto"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// TimeOut 全局请求超时设置,默认1分钟
+// globalMu 保护以下全局配置，运行时请通过Set系列函数修改，避免数据竞争
+var globalMu sync.RWMutex
+
+// TimeOut 全局请求超时设置,默认1分钟，请通过SetTimeOut修改
 var TimeOut time.Duration = 60 * time.Second
 
-// Proxy 代理
+// Proxy 代理，请通过SetProxy修改
 var Proxy func(*http.Request) (*url.URL, error)
 
+// Version SDK版本号
+const Version = "1.0.0"
+
+// UserAgent 全局User-Agent，为空时不发送User-Agent，请通过SetUserAgent修改
+var UserAgent = "kkkunny-wechat/" + Version
+
+// SetUserAgent 设置全局User-Agent，传入空字符串则不发送
+func SetUserAgent(ua string) {
+	globalMu.Lock()
+	UserAgent = ua
+	globalMu.Unlock()
+}
+
+func getUserAgent() string {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return UserAgent
+}
+
+// MaxResponseBytes 全局响应体大小上限，默认32MB，为0时不限制，请通过SetMaxResponseBytes修改
+// 仅作用于读取整个响应体的函数，GetFile、GetRawBody等流式下载不受限制
+var MaxResponseBytes int64 = 32 << 20
+
+// SetMaxResponseBytes 设置全局响应体大小上限，传入0则不限制
+func SetMaxResponseBytes(n int64) {
+	globalMu.Lock()
+	MaxResponseBytes = n
+	globalMu.Unlock()
+}
+
+func getMaxResponseBytes() int64 {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return MaxResponseBytes
+}
+
+// ErrResponseTooLarge 响应体超过MaxResponseBytes
+var ErrResponseTooLarge = errors.New("http response body too large")
+
+// maxBytesReader 读取超过n字节时返回ErrResponseTooLarge
+type maxBytesReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.n < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.r.Read(p)
+	if int64(n) > m.n {
+		n = int(m.n)
+		m.n = -1
+		return n, ErrResponseTooLarge
+	}
+	m.n -= int64(n)
+	return n, err
+}
+
+// LimitBody 按Client的响应体上限包装r，超出时读取返回ErrResponseTooLarge，用于读取DoCtx返回的原始响应
+func (c *Client) LimitBody(r io.Reader) io.Reader {
+	return c.limitBody(r)
+}
+
+// limitBody 按Client的响应体上限包装r
+func (c *Client) limitBody(r io.Reader) io.Reader {
+	n := c.maxResponseBytes()
+	if n <= 0 {
+		return r
+	}
+	return &maxBytesReader{r: r, n: n}
+}
+
+// SetRateLimit 设置DefaultClient的限流，rps<=0时取消限流
+func SetRateLimit(rps float64, burst int) {
+	DefaultClient.SetRateLimit(rps, burst)
+}
+
+// LogFunc 请求日志回调，每次发送请求(含重试)后调用
+// req的URL已隐去access_token等敏感参数，回调中不应读取resp.Body
+type LogFunc func(req *http.Request, resp *http.Response, err error, dur time.Duration)
+
+// SetLogger 设置DefaultClient的请求日志回调，传入nil则关闭
+func SetLogger(fn LogFunc) {
+	DefaultClient.SetLogger(fn)
+}
+
+// redactKeys 日志中需要隐去的请求参数
+var redactKeys = []string{"access_token", "secret", "corpsecret", "appsecret", "js_code", "code", "refresh_token"}
+
+// redactURL 返回隐去敏感参数后的URL副本
+func redactURL(u *url.URL) *url.URL {
+	r := *u
+	q := r.Query()
+	for _, k := range redactKeys {
+		if q.Get(k) != "" {
+			q.Set(k, "***")
+		}
+	}
+	r.RawQuery = q.Encode()
+	return &r
+}
+
+// logRequest 调用日志回调
+func (c *Client) logRequest(req *http.Request, resp *http.Response, err error, start time.Time) {
+	logger := c.getLogger()
+	if logger == nil {
+		return
+	}
+	r := req.Clone(req.Context())
+	r.URL = redactURL(req.URL)
+	logger(r, resp, err, time.Since(start))
+}
+
+// SetTransport 设置DefaultClient的RoundTripper，传入nil则恢复默认Transport
+func SetTransport(rt http.RoundTripper) {
+	DefaultClient.SetTransport(rt)
+}
+
 // SetTimeOut 设置全局请求超时
 func SetTimeOut(d time.Duration) {
+	globalMu.Lock()
 	TimeOut = d
+	globalMu.Unlock()
+}
+
+func getTimeOut() time.Duration {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return TimeOut
 }
 
 // SetProxy 设置全局代理
 func SetProxy(p func(*http.Request) (*url.URL, error)) {
+	globalMu.Lock()
 	Proxy = p
+	globalMu.Unlock()
 }
 
-// httpClient() 带超时的http.Client
-func httpClient() *http.Client {
-	cli := &http.Client{Timeout: TimeOut}
-	if Proxy != nil {
-		cli.Transport = &http.Transport{Proxy: Proxy}
+func getProxy() func(*http.Request) (*url.URL, error) {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return Proxy
+}
+
+// requestOptions 单次请求的配置
+type requestOptions struct {
+	timeout  time.Duration
+	headers  http.Header
+	progress ProgressFunc
+
+	checkErrCode bool
+	checkMedia   bool
+	mediaType    string
+}
+
+// RequestOption 单次请求配置项，传入各Ctx请求函数
+type RequestOption func(*requestOptions)
+
+// WithTimeout 设置单次请求超时，覆盖全局TimeOut，不修改全局设置
+func WithTimeout(d time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = d
 	}
-	return cli
 }
 
-// GetJson 发送GET请求解析json
-func GetJson(uri string, v interface{}) error {
+// WithHeaders 为单次请求附加请求头，如WeChat Pay v3的Authorization
+func WithHeaders(h http.Header) RequestOption {
+	return func(o *requestOptions) {
+		if o.headers == nil {
+			o.headers = make(http.Header)
+		}
+		for k, v := range h {
+			o.headers[k] = append(o.headers[k], v...)
+		}
+	}
+}
 
-	r, err := httpClient().Get(uri)
-	if err != nil {
-		return err
+// ProgressFunc 传输进度回调，total未知时为-1
+type ProgressFunc func(transferred, total int64)
+
+// WithProgress 设置传输进度回调，带请求体时回调上传进度，否则回调下载进度
+func WithProgress(fn ProgressFunc) RequestOption {
+	return func(o *requestOptions) {
+		o.progress = fn
 	}
-	defer r.Body.Close()
-	return json.NewDecoder(r.Body).Decode(v)
 }
 
-// GetXml 发送GET请求并解析xml
-func GetXml(uri string, v interface{}) error {
-	r, err := httpClient().Get(uri)
-	if err != nil {
-		return err
+// progressReader 读取时回调进度
+type progressReader struct {
+	io.ReadCloser
+	n, total int64
+	fn       ProgressFunc
+}
+
+func newProgressReader(r io.ReadCloser, total int64, fn ProgressFunc) *progressReader {
+	if total <= 0 {
+		total = -1
 	}
-	defer r.Body.Close()
-	return xml.NewDecoder(r.Body).Decode(v)
+	return &progressReader{ReadCloser: r, total: total, fn: fn}
 }
 
-// GetBody 发送GET请求，返回body字节
-func GetBody(uri string) ([]byte, error) {
-	resp, err := httpClient().Get(uri)
-	if err != nil {
-		return nil, err
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.fn(r.n, r.total)
 	}
-	defer resp.Body.Close()
+	return n, err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http get err: uri=%v , statusCode=%v", uri, resp.StatusCode)
+func (c *Client) newRequestOptions(opts []RequestOption) *requestOptions {
+	o := &requestOptions{timeout: c.timeout()}
+	for _, opt := range opts {
+		opt(o)
 	}
-	return ioutil.ReadAll(resp.Body)
+	return o
 }
 
-// GetRawBody 发送GET请求，返回body字节
-// func GetRawBody(uri string) (io.ReadCloser, error) {
-// 	resp, err := httpClient().Get(uri)
-// 	if err != nil {
-// 		return nil, err
-// 	}
+// cancelBody 关闭body时释放超时ctx
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
-// 	if resp.StatusCode != http.StatusOK {
-// 		return nil, fmt.Errorf("http get err: uri=%v , statusCode=%v", uri, resp.StatusCode)
-// 	}
-// 	return resp.Body, nil
-// }
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
 
-// PostJson 发送Json格式的POST请求
-func PostJson(uri string, obj interface{}) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	enc := json.NewEncoder(buf)
-	enc.SetEscapeHTML(false)
-	err := enc.Encode(obj)
+// doRequest 创建带ctx的请求并发送
+func (c *Client) doRequest(ctx context.Context, method, uri, contentType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	o := c.newRequestOptions(opts)
+	cancel := context.CancelFunc(func() {})
+	if o.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	resp, err := httpClient().Post(uri, "application/json;charset=utf-8", buf)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("User-Agent", c.getUserAgent())
+	for k, v := range o.headers {
+		req.Header[k] = v
+	}
+	if o.progress != nil && req.Body != nil {
+		req.Body = newProgressReader(req.Body, req.ContentLength, o.progress)
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				b, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newProgressReader(b, req.ContentLength, o.progress), nil
+			}
+		}
+	}
+	resp, err := c.doWithRetry(ctx, req)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http post error : uri=%v , statusCode=%v", uri, resp.StatusCode)
+	if err = decompressBody(resp); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+	if o.progress != nil && req.Body == nil {
+		resp.Body = newProgressReader(resp.Body, resp.ContentLength, o.progress)
 	}
-	return ioutil.ReadAll(resp.Body)
+	resp.Body = &cancelBody{resp.Body, cancel}
+	return resp, nil
 }
 
-// PostJsonPtr 发送Json格式的POST请求并解析结果到result指针
-func PostJsonPtr(uri string, obj interface{}, result interface{}, contentType ...string) (err error) {
-	buf := new(bytes.Buffer)
-	enc := json.NewEncoder(buf)
-	//	enc.SetEscapeHTML(false)
-	err = enc.Encode(obj)
-	if err != nil {
-		return
+// decompressBody 部分接口或反向代理返回压缩数据，依据Content-Encoding透明解压
+func decompressBody(resp *http.Response) error {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(resp.Body)
+	case "deflate":
+		r, err = zlib.NewReader(resp.Body)
+	default:
+		return nil
 	}
-	ct := "application/json;charset=utf-8"
-	if len(contentType) > 0 {
-		ct = strings.Join(contentType, ";")
-	}
-	// fmt.Println("post buf:", buf.String()) // Debug
-	resp, err := httpClient().Post(uri, ct, buf)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	resp.Body = &decompressReader{ReadCloser: r, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decompressReader 关闭时同时关闭原始body
+type decompressReader struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http post error : uri=%v , statusCode=%v", uri, resp.StatusCode)
+func (r *decompressReader) Close() error {
+	r.ReadCloser.Close()
+	return r.raw.Close()
+}
+
+// RetryPolicy 重试策略，仅在网络错误及429/502/503/504时重试，MaxAttempts<=1表示不重试
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数(含首次)
+	BaseDelay   time.Duration // 首次重试的等待时间，之后指数递增
+	MaxDelay    time.Duration // 单次等待时间上限
+}
+
+var retryPolicy RetryPolicy
+
+// SetRetryPolicy 设置全局重试策略
+func SetRetryPolicy(p RetryPolicy) {
+	globalMu.Lock()
+	retryPolicy = p
+	globalMu.Unlock()
+}
+
+func getRetryPolicy() RetryPolicy {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return retryPolicy
+}
+
+// backoff 第n次重试前的等待时间，指数退避并加入随机抖动
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay << uint(n)
+	if p.MaxDelay > 0 && (d <= 0 || d > p.MaxDelay) {
+		d = p.MaxDelay
 	}
-	return json.NewDecoder(resp.Body).Decode(result)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
 }
 
-// PostXmlPtr 发送Xml格式的POST请求并解析结果到result指针
-func PostXmlPtr(uri string, obj interface{}, result interface{}) (err error) {
-	buf := new(bytes.Buffer)
-	enc := xml.NewEncoder(buf)
-	//	enc.SetEscapeHTML(false)
-	err = enc.Encode(obj)
+// retryAfter 解析Retry-After头，支持秒数与HTTP-date两种格式
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n < 0 {
+			return 0, false
+		}
+		return time.Duration(n) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
 	if err != nil {
-		return
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
 	}
+	return 0, true
+}
 
-	resp, err := httpClient().Post(uri, "application/xml;charset=utf-8", buf)
-	if err != nil {
-		return err
+// delay 第n次重试前的等待时间，resp带有Retry-After时优先使用，但不超过MaxDelay
+func (p RetryPolicy) delay(n int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				d = p.MaxDelay
+			}
+			return d
+		}
 	}
-	defer resp.Body.Close()
+	return p.backoff(n)
+}
+
+// countReader 统计已被读取(已发送)的请求体字节数
+type countReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http post error : uri=%v , statusCode=%v", uri, resp.StatusCode)
+// isIdempotent 幂等请求可安全重试
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
 	}
-	return xml.NewDecoder(resp.Body).Decode(result)
+	return false
 }
 
-// PostFileBytes 上传文件
-func PostFileBytes(fieldname string, filename string, contentType string, data []byte, uri string) ([]byte, error) {
-	fields := []MultipartFormField{
-		{
-			Fieldname:   fieldname,
-			Value:       data,
-			ContentType: contentType,
-			Filename:    filename,
-		},
+// shouldRetryStatus 限流及网关类错误可重试
+func shouldRetryStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// doWithRetry 按全局重试策略发送请求，非幂等请求仅在请求体未发出任何字节时重试
+func (c *Client) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	p := getRetryPolicy()
+	for i := 0; ; i++ {
+		var cr *countReader
+		if req.Body != nil && req.Body != http.NoBody {
+			cr = &countReader{ReadCloser: req.Body}
+			req.Body = cr
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := c.httpClient().Do(req)
+		c.logRequest(req, resp, err, start)
+
+		last := i+1 >= p.MaxAttempts || ctx.Err() != nil
+		if !last && cr != nil && cr.n > 0 && !isIdempotent(req.Method) {
+			last = true
+		}
+		if !last && (req.Body != nil && req.Body != http.NoBody) && req.GetBody == nil {
+			last = true
+		}
+		if !last && err == nil && !shouldRetryStatus(resp.StatusCode) {
+			last = true
+		}
+		if last {
+			return resp, err
+		}
+		wait := p.delay(i, resp)
+		if err == nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if req.GetBody != nil {
+			b, e := req.GetBody()
+			if e != nil {
+				return nil, e
+			}
+			req = req.Clone(ctx)
+			req.Body = b
+		}
 	}
-	return PostMultipartForm(fields, uri)
 }
 
-// GetFile 下载文件
-func GetFile(filename, uri string) error {
-	resp, err := httpClient().Get(uri)
-	if err != nil {
-		return err
+// maxErrorBody 非200错误中保留的响应体上限
+const maxErrorBody = 4 << 10
+
+// HTTPError 非200响应错误，Body为截断后的响应体
+type HTTPError struct {
+	Method     string
+	URI        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http %s error : uri=%v , statusCode=%v , body=%s", strings.ToLower(e.Method), e.URI, e.StatusCode, e.Body)
+}
+
+// CheckStatus 检查GetResponse等返回的原始响应，非200时读取截断的响应体并返回*HTTPError，调用方负责关闭resp.Body
+func CheckStatus(resp *http.Response, uri string) error {
+	method := http.MethodGet
+	if resp.Request != nil {
+		method = resp.Request.Method
 	}
-	defer resp.Body.Close()
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	return checkStatus(resp, method, uri)
+}
+
+// checkStatus 检查响应状态码，非200时读取截断的响应体并返回*HTTPError
+// method由调用方传入，自定义RoundTripper返回的resp.Request可能为nil
+func checkStatus(resp *http.Response, method, uri string) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
 	}
-	defer file.Close()
-	_, err = io.Copy(file, resp.Body)
-	return err
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &HTTPError{
+		Method:     method,
+		URI:        uri,
+		StatusCode: resp.StatusCode,
+		Body:       body,
+	}
+}
+
+// GetJson 发送GET请求解析json
+func GetJson(uri string, v interface{}) error {
+	return DefaultClient.GetJson(uri, v)
+}
+
+// GetJsonCtx 发送GET请求解析json，支持ctx取消
+func GetJsonCtx(ctx context.Context, uri string, v interface{}, opts ...RequestOption) error {
+	return DefaultClient.GetJsonCtx(ctx, uri, v, opts...)
+}
+
+// GetXml 发送GET请求并解析xml
+func GetXml(uri string, v interface{}) error {
+	return DefaultClient.GetXml(uri, v)
+}
+
+// GetXmlCtx 发送GET请求并解析xml，支持ctx取消
+func GetXmlCtx(ctx context.Context, uri string, v interface{}, opts ...RequestOption) error {
+	return DefaultClient.GetXmlCtx(ctx, uri, v, opts...)
+}
+
+// GetBody 发送GET请求，返回body字节
+func GetBody(uri string) ([]byte, error) {
+	return DefaultClient.GetBody(uri)
+}
+
+// GetBodyCtx 发送GET请求，返回body字节，支持ctx取消
+func GetBodyCtx(ctx context.Context, uri string, opts ...RequestOption) ([]byte, error) {
+	return DefaultClient.GetBodyCtx(ctx, uri, opts...)
+}
+
+// GetBodyWithHeaders 发送带自定义请求头的GET请求，返回body字节
+func GetBodyWithHeaders(uri string, headers http.Header) ([]byte, error) {
+	return DefaultClient.GetBodyWithHeaders(uri, headers)
+}
+
+// GetBodyWithResponse 发送GET请求，返回body字节及响应头，可用于读取Content-Type、Content-Disposition
+func GetBodyWithResponse(uri string) ([]byte, http.Header, error) {
+	return DefaultClient.GetBodyWithResponse(uri)
+}
+
+// GetBodyWithResponseCtx 发送GET请求，返回body字节及响应头，支持ctx取消
+func GetBodyWithResponseCtx(ctx context.Context, uri string, opts ...RequestOption) ([]byte, http.Header, error) {
+	return DefaultClient.GetBodyWithResponseCtx(ctx, uri, opts...)
+}
+
+// GetResponse 发送GET请求，返回原始响应，不检查状态码，调用方负责关闭resp.Body
+func GetResponse(uri string) (*http.Response, error) {
+	return DefaultClient.GetResponse(uri)
+}
+
+// GetResponseCtx 发送GET请求，返回原始响应，支持ctx取消，调用方负责关闭resp.Body
+func GetResponseCtx(ctx context.Context, uri string, opts ...RequestOption) (*http.Response, error) {
+	return DefaultClient.GetResponseCtx(ctx, uri, opts...)
+}
+
+// DoCtx 按method发送请求，返回原始响应，不检查状态码，调用方负责关闭resp.Body
+func DoCtx(ctx context.Context, method, uri, contentType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return DefaultClient.DoCtx(ctx, method, uri, contentType, body, opts...)
+}
+
+// GetRawBody 发送GET请求，返回未读取的响应体，用于流式转发大文件
+// 调用方负责关闭返回的io.ReadCloser
+func GetRawBody(uri string) (io.ReadCloser, error) {
+	return DefaultClient.GetRawBody(uri)
+}
+
+// GetRawBodyCtx 发送GET请求，返回未读取的响应体，支持ctx取消
+// 调用方负责关闭返回的io.ReadCloser，超时设置对读取body的过程同样有效
+func GetRawBodyCtx(ctx context.Context, uri string, opts ...RequestOption) (io.ReadCloser, error) {
+	return DefaultClient.GetRawBodyCtx(ctx, uri, opts...)
+}
+
+// PostJson 发送Json格式的POST请求
+func PostJson(uri string, obj interface{}) ([]byte, error) {
+	return DefaultClient.PostJson(uri, obj)
+}
+
+// PostJsonCtx 发送Json格式的POST请求，支持ctx取消
+func PostJsonCtx(ctx context.Context, uri string, obj interface{}, opts ...RequestOption) ([]byte, error) {
+	return DefaultClient.PostJsonCtx(ctx, uri, obj, opts...)
 }
 
-// MultipartFormField 文件或其他表单数据
+// PostJsonPtr 发送Json格式的POST请求并解析结果到result指针
+func PostJsonPtr(uri string, obj interface{}, result interface{}, contentType ...string) (err error) {
+	return DefaultClient.PostJsonPtr(uri, obj, result, contentType...)
+}
+
+// PostJsonPtrCtx 发送Json格式的POST请求并解析结果到result指针，支持ctx取消
+func PostJsonPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) (err error) {
+	return DefaultClient.PostJsonPtrCtx(ctx, uri, obj, result, opts...)
+}
+
+// PutJsonPtr 发送Json格式的PUT请求并解析结果到result指针
+func PutJsonPtr(uri string, obj interface{}, result interface{}) error {
+	return DefaultClient.PutJsonPtr(uri, obj, result)
+}
+
+// PutJsonPtrCtx 发送Json格式的PUT请求并解析结果到result指针，支持ctx取消
+func PutJsonPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) error {
+	return DefaultClient.PutJsonPtrCtx(ctx, uri, obj, result, opts...)
+}
+
+// PatchJsonPtr 发送Json格式的PATCH请求并解析结果到result指针
+func PatchJsonPtr(uri string, obj interface{}, result interface{}) error {
+	return DefaultClient.PatchJsonPtr(uri, obj, result)
+}
+
+// PatchJsonPtrCtx 发送Json格式的PATCH请求并解析结果到result指针，支持ctx取消
+func PatchJsonPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) error {
+	return DefaultClient.PatchJsonPtrCtx(ctx, uri, obj, result, opts...)
+}
+
+// DeleteJson 发送DELETE请求并解析结果到result指针，obj为nil时不带请求体
+func DeleteJson(uri string, obj interface{}, result interface{}) error {
+	return DefaultClient.DeleteJson(uri, obj, result)
+}
+
+// DeleteJsonCtx 发送DELETE请求并解析结果到result指针，支持ctx取消
+func DeleteJsonCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) error {
+	return DefaultClient.DeleteJsonCtx(ctx, uri, obj, result, opts...)
+}
+
+// PostForm 发送application/x-www-form-urlencoded格式的POST请求并解析json结果到result指针
+func PostForm(uri string, values url.Values, result interface{}) error {
+	return DefaultClient.PostForm(uri, values, result)
+}
+
+// PostFormCtx 发送表单格式的POST请求并解析json结果到result指针，支持ctx取消
+func PostFormCtx(ctx context.Context, uri string, values url.Values, result interface{}, opts ...RequestOption) error {
+	return DefaultClient.PostFormCtx(ctx, uri, values, result, opts...)
+}
+
+// PostXmlPtr 发送Xml格式的POST请求并解析结果到result指针
+func PostXmlPtr(uri string, obj interface{}, result interface{}) (err error) {
+	return DefaultClient.PostXmlPtr(uri, obj, result)
+}
+
+// PostXmlPtrCtx 发送Xml格式的POST请求并解析结果到result指针，支持ctx取消
+func PostXmlPtrCtx(ctx context.Context, uri string, obj interface{}, result interface{}, opts ...RequestOption) (err error) {
+	return DefaultClient.PostXmlPtrCtx(ctx, uri, obj, result, opts...)
+}
+
+// PostFileBytes 上传文件
+func PostFileBytes(fieldname string, filename string, contentType string, data []byte, uri string) ([]byte, error) {
+	return DefaultClient.PostFileBytes(fieldname, filename, contentType, data, uri)
+}
+
+// PostFileBytesCtx 上传文件，支持ctx取消
+func PostFileBytesCtx(ctx context.Context, fieldname string, filename string, contentType string, data []byte, uri string, opts ...RequestOption) ([]byte, error) {
+	return DefaultClient.PostFileBytesCtx(ctx, fieldname, filename, contentType, data, uri, opts...)
+}
+
+// GetFile 下载文件，filename为空时按响应头在当前目录生成文件名，见SaveFile
+func GetFile(filename, uri string) error {
+	return DefaultClient.GetFile(filename, uri)
+}
+
+// GetFileCtx 下载文件，支持ctx取消，下载失败时删除已创建的文件
+func GetFileCtx(ctx context.Context, filename, uri string, opts ...RequestOption) error {
+	return DefaultClient.GetFileCtx(ctx, filename, uri, opts...)
+}
+
+// SaveFile 下载文件到dir目录，文件名取自Content-Disposition，没有时随机生成并按Content-Type补全扩展名
+// 返回保存的文件路径
+func SaveFile(dir, uri string) (string, error) {
+	return DefaultClient.SaveFile(dir, uri)
+}
+
+// SaveFileCtx 下载文件到dir目录，支持ctx取消
+func SaveFileCtx(ctx context.Context, dir, uri string, opts ...RequestOption) (string, error) {
+	return DefaultClient.SaveFileCtx(ctx, dir, uri, opts...)
+}
+
+// GetFileResume 断点续传下载文件，已存在的部分通过Range请求跳过
+func GetFileResume(filename, uri string) error {
+	return DefaultClient.GetFileResume(filename, uri)
+}
+
+// GetFileResumeCtx 断点续传下载文件，支持ctx取消
+func GetFileResumeCtx(ctx context.Context, filename, uri string, opts ...RequestOption) error {
+	return DefaultClient.GetFileResumeCtx(ctx, filename, uri, opts...)
+}
+
+// GetToWriter 发送GET请求，将响应体写入w，返回写入的字节数
+func GetToWriter(w io.Writer, uri string) (int64, error) {
+	return DefaultClient.GetToWriter(w, uri)
+}
+
+// GetToWriterCtx 发送GET请求，将响应体写入w，返回写入的字节数，支持ctx取消
+func GetToWriterCtx(ctx context.Context, w io.Writer, uri string, opts ...RequestOption) (int64, error) {
+	return DefaultClient.GetToWriterCtx(ctx, w, uri, opts...)
+}
+
+// MultipartFormField 文件或其他表单数据，Filename为空时作为普通文本字段写入
 type MultipartFormField struct {
 	Fieldname   string
 	Value       []byte
 	ContentType string
 	Filename    string
+	Reader      io.Reader // 设置后代替Value，流式读取字段内容
+	Length      int64     // Reader内容长度，未知时为0
 }
 
 // PostMultipartForm 上传文件或其他表单数据
 func PostMultipartForm(fields []MultipartFormField, uri string) (respBody []byte, err error) {
-	bodyBuf := &bytes.Buffer{}
-	bodyWriter := multipart.NewWriter(bodyBuf)
-
-	for _, field := range fields {
-		h := make(textproto.MIMEHeader)
-		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"; filelength=%d`, field.Fieldname, field.Filename, len(field.Value)))
-		h.Set("Content-Type", field.ContentType)
-		partWriter, e := bodyWriter.CreatePart(h)
-		if e != nil {
-			err = e
-			return
-		}
-		valueReader := bytes.NewReader(field.Value)
-		if _, err = io.Copy(partWriter, valueReader); err != nil {
-			return
-		}
-	}
-
-	contentType := bodyWriter.FormDataContentType()
-	bodyWriter.Close()
-
-	resp, e := httpClient().Post(uri, contentType, bodyBuf)
-	if e != nil {
-		err = e
-		return
-	}
-	defer resp.Body.Close()
+	return DefaultClient.PostMultipartForm(fields, uri)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
-	}
-	return ioutil.ReadAll(resp.Body)
+// PostMultipartFormCtx 上传文件或其他表单数据，支持ctx取消
+func PostMultipartFormCtx(ctx context.Context, fields []MultipartFormField, uri string, opts ...RequestOption) (respBody []byte, err error) {
+	return DefaultClient.PostMultipartFormCtx(ctx, fields, uri, opts...)
 }
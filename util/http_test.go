@@ -0,0 +1,262 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostMultipartFormStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"errcode":-1,"errmsg":"system error"}`))
+	}))
+	defer ts.Close()
+
+	b, err := PostFileBytes("media", "a.jpg", "image/jpeg", []byte("data"), ts.URL)
+	if err == nil {
+		t.Fatalf("expected error on 500, got body %q", b)
+	}
+	e, ok := err.(*HTTPError)
+	if !ok {
+		t.Fatalf("expected *HTTPError, got %T", err)
+	}
+	if e.StatusCode != http.StatusInternalServerError || string(e.Body) != `{"errcode":-1,"errmsg":"system error"}` {
+		t.Errorf("unexpected error: %v", e)
+	}
+}
+
+func TestPostMultipartFormMixedFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, h, err := r.FormFile("media")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		data, _ := ioutil.ReadAll(f)
+		if h.Filename != "a.mp4" || string(data) != "video" {
+			t.Errorf("unexpected file part: %v %q", h.Filename, data)
+		}
+		if _, _, err = r.FormFile("description"); err != http.ErrMissingFile {
+			t.Errorf("description should be a plain field, got %v", err)
+		}
+		w.Write([]byte(r.FormValue("description")))
+	}))
+	defer ts.Close()
+
+	desc := `{"title":"t","introduction":"i"}`
+	b, err := PostMultipartForm([]MultipartFormField{
+		{Fieldname: "media", Filename: "a.mp4", ContentType: "video/mp4", Reader: strings.NewReader("video")},
+		{Fieldname: "description", Value: []byte(desc)},
+	}, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != desc {
+		t.Errorf("got description %q, want %q", b, desc)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		v    string
+		want time.Duration
+		ok   bool
+	}{
+		{"120", 120 * time.Second, true},
+		{"0", 0, true},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		d, ok := retryAfter(c.v, now)
+		if d != c.want || ok != c.ok {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", c.v, d, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestRetryAfterCappedByMaxDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	resp := &http.Response{Header: http.Header{"Retry-After": {"3600"}}}
+	if d := p.delay(0, resp); d != p.MaxDelay {
+		t.Errorf("got delay %v, want %v", d, p.MaxDelay)
+	}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if d := p.delay(0, resp); d != p.MaxDelay {
+		t.Errorf("got delay %v, want %v", d, p.MaxDelay)
+	}
+}
+
+func TestRetryOn429(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	defer SetRetryPolicy(RetryPolicy{})
+	b, err := GetBody(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ok" || n != 2 {
+		t.Errorf("got %q after %d attempts", b, n)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestCheckStatusWithoutRequest(t *testing.T) {
+	c := NewClient(WithClientTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("boom")),
+		}, nil
+	})))
+
+	_, err := c.GetBody("http://example.com/get")
+	e, ok := err.(*HTTPError)
+	if !ok {
+		t.Fatalf("expected *HTTPError, got %T %v", err, err)
+	}
+	if e.Method != http.MethodGet || e.StatusCode != http.StatusInternalServerError || string(e.Body) != "boom" {
+		t.Errorf("unexpected error: %v", e)
+	}
+
+	err = c.PostJsonPtr("http://example.com/post", map[string]string{}, &struct{}{})
+	if e, ok = err.(*HTTPError); !ok || e.Method != http.MethodPost {
+		t.Errorf("expected POST *HTTPError, got %T %v", err, err)
+	}
+}
+
+func TestClientConfigAfterFirstUse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	c := NewClient()
+	if b, err := c.GetBody(ts.URL + "/redirect"); err != nil || string(b) != "ok" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	c.NoRedirect = true
+	resp, err := c.GetResponse(ts.URL + "/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("NoRedirect set after first use ignored, got status %v", resp.StatusCode)
+	}
+}
+
+func TestHTTPClientWithTransport(t *testing.T) {
+	c := NewClient(WithHTTPClient(&http.Client{}), WithClientTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("stub"))}, nil
+	})))
+	if b, err := c.GetBody("http://example.com/"); err != nil || string(b) != "stub" {
+		t.Errorf("SetTransport ignored with HTTPClient, got %q, %v", b, err)
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(strings.Repeat("x", maxErrorBody+1)))}
+	e, ok := CheckStatus(resp, "http://example.com/media").(*HTTPError)
+	if !ok {
+		t.Fatal("expected *HTTPError")
+	}
+	if e.Method != http.MethodGet || e.StatusCode != http.StatusBadGateway || len(e.Body) != maxErrorBody {
+		t.Errorf("unexpected error: method=%v status=%v body=%d bytes", e.Method, e.StatusCode, len(e.Body))
+	}
+	if err := CheckStatus(&http.Response{StatusCode: http.StatusOK}, "http://example.com/media"); err != nil {
+		t.Errorf("expected nil for 200, got %v", err)
+	}
+}
+
+func TestWithMediaType(t *testing.T) {
+	ct := "image/jpeg"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ct)
+		w.Write([]byte("<html>error</html>"))
+	}))
+	defer ts.Close()
+
+	if b, _, err := GetBodyWithResponseCtx(context.Background(), ts.URL, WithMediaType("image/")); err != nil || string(b) != "<html>error</html>" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	for _, ct = range []string{"text/html", ""} {
+		b, _, err := GetBodyWithResponseCtx(context.Background(), ts.URL, WithMediaType("image/"))
+		if err == nil || !strings.Contains(err.Error(), "<html>error</html>") {
+			t.Errorf("content-type %q: got %q, %v", ct, b, err)
+		}
+	}
+}
+
+func TestGetFileResume(t *testing.T) {
+	const content = "abcdef"
+	var wrongRange bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wrongRange {
+			w.Header().Set("Content-Range", "bytes 0-5/6")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(content))
+			return
+		}
+		http.ServeContent(w, r, "a.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.txt")
+	read := func() string {
+		b, _ := ioutil.ReadFile(filename)
+		return string(b)
+	}
+	ioutil.WriteFile(filename, []byte("abc"), 0666)
+	if err := GetFileResume(filename, ts.URL); err != nil || read() != content {
+		t.Fatalf("resume got %q, %v", read(), err)
+	}
+	if err := GetFileResume(filename, ts.URL); err != nil || read() != content {
+		t.Fatalf("complete file got %q, %v", read(), err)
+	}
+
+	ioutil.WriteFile(filename, []byte("abcdefgh"), 0666)
+	if err := GetFileResume(filename, ts.URL); err == nil {
+		t.Error("expected error when local file is larger than remote")
+	}
+
+	wrongRange = true
+	ioutil.WriteFile(filename, []byte("abc"), 0666)
+	if err := GetFileResume(filename, ts.URL); err == nil || read() != "abc" {
+		t.Errorf("expected error for mismatched content-range, got %q, %v", read(), err)
+	}
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter 令牌桶限流器，用于避免超出微信接口调用频率限制(errcode=45009)
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒生成的令牌数
+	burst  float64 // 令牌桶容量
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建限流器，rps为每秒请求数，burst为允许的突发请求数，rps<=0时不限流
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait 阻塞直到获得一个令牌，ctx结束时返回ctx.Err()
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterNoLimit(t *testing.T) {
+	for _, rps := range []float64{0, -1} {
+		l := NewRateLimiter(rps, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		for i := 0; i < 100; i++ {
+			if err := l.Wait(ctx); err != nil {
+				t.Fatalf("rps=%v: wait %d returned %v", rps, i, err)
+			}
+		}
+		cancel()
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(100, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 5*time.Millisecond {
+		t.Errorf("second wait returned after %v, want about 10ms", d)
+	}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// WechatError 微信接口返回的errcode/errmsg错误
+type WechatError struct {
+	Code int    `json:"errcode"`
+	Msg  string `json:"errmsg"`
+}
+
+func (e *WechatError) Error() string {
+	return fmt.Sprintf("err: errcode=%v , errmsg=%v", e.Code, e.Msg)
+}
+
+// CheckErrCode 检查json响应中的errcode，非0时返回*WechatError，非json数据不视为错误
+func CheckErrCode(body []byte) error {
+	e := new(WechatError)
+	if json.Unmarshal(body, e) != nil || e.Code == 0 {
+		return nil
+	}
+	return e
+}
+
+// IsErrCode 判断err是否为指定errcode的微信接口错误
+func IsErrCode(err error, code int) bool {
+	var e *WechatError
+	return errors.As(err, &e) && e.Code == code
+}
+
+// WithCheckErrCode 解析json结果前先检查errcode，非0时返回*WechatError
+func WithCheckErrCode() RequestOption {
+	return func(o *requestOptions) {
+		o.checkErrCode = true
+	}
+}
+
+// WithMediaCheck 下载文件类接口检查响应，微信出错时返回json而非文件，此时返回*WechatError
+// 仅作用于GetBodyWithResponse、GetToWriter、GetFile、SaveFile等下载函数
+func WithMediaCheck() RequestOption {
+	return func(o *requestOptions) {
+		o.checkMedia = true
+	}
+}
+
+// WithMediaType 同WithMediaCheck，并要求Content-Type以prefix开头(如"image/")，否则返回带截断响应体的错误
+func WithMediaType(prefix string) RequestOption {
+	return func(o *requestOptions) {
+		o.checkMedia = true
+		o.mediaType = prefix
+	}
+}
+
+// checkMediaResponse 响应为json时视为错误，text/plain响应中带有errcode时同样视为错误
+// mediaType不为空时，Content-Type不以其开头同样视为错误
+func checkMediaResponse(resp *http.Response, mediaType string) error {
+	ct := resp.Header.Get("Content-Type")
+	isJson := strings.Contains(ct, "json")
+	expected := mediaType == "" || strings.HasPrefix(ct, mediaType)
+	if !isJson && !strings.HasPrefix(ct, "text/plain") && expected {
+		return nil
+	}
+	head, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return err
+	}
+	if err = CheckErrCode(head); err != nil {
+		return err
+	}
+	if isJson {
+		return fmt.Errorf("unexpected json response: %s", head)
+	}
+	if !expected {
+		return fmt.Errorf("unexpected content-type %q, want %v: %s", ct, mediaType, head)
+	}
+	resp.Body = readCloser{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	return nil
+}
+
+// readCloser 组合Reader与Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// decodeJson 解析json结果到v，按配置检查errcode
+func decodeJson(r io.Reader, v interface{}, o *requestOptions) error {
+	if !o.checkErrCode {
+		return json.NewDecoder(r).Decode(v)
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err = CheckErrCode(body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,166 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXAPIWxaCodeUnlimited 小程序码接口
+const (
+	WXAPIWxaCodeUnlimited = WXAAPI + "getwxacodeunlimit?access_token="
+	WXAPIWxaCode          = WXAAPI + "getwxacode?access_token="
+	WXAPIWxaQrcode        = WXAPI + "wxaapp/createwxaqrcode?access_token="
+)
+
+// 小程序码参数长度限制
+const (
+	WxaCodeSceneMax = 32  // 无限制小程序码scene最大长度
+	WxaCodePathMax  = 128 // 有限制小程序码及二维码path最大长度
+)
+
+// WxaLineColor 小程序码线条颜色，auto_color为false时生效
+type WxaLineColor struct {
+	R int `json:"r"`
+	G int `json:"g"`
+	B int `json:"b"`
+}
+
+// wxaCodeOptions 小程序码可选参数
+type wxaCodeOptions struct {
+	Width      int           `json:"width,omitempty"` // 280-1280，默认430
+	AutoColor  bool          `json:"auto_color,omitempty"`
+	LineColor  *WxaLineColor `json:"line_color,omitempty"`
+	IsHyaline  bool          `json:"is_hyaline,omitempty"`
+	EnvVersion string        `json:"env_version,omitempty"` // release、trial、develop，默认release
+	CheckPath  *bool         `json:"check_path,omitempty"`
+}
+
+// WxaCodeOption 小程序码可选参数
+type WxaCodeOption func(*wxaCodeOptions)
+
+// WithWxaCodeWidth 设置二维码宽度，单位px
+func WithWxaCodeWidth(width int) WxaCodeOption {
+	return func(o *wxaCodeOptions) {
+		o.Width = width
+	}
+}
+
+// WithWxaCodeAutoColor 自动配置线条颜色
+func WithWxaCodeAutoColor() WxaCodeOption {
+	return func(o *wxaCodeOptions) {
+		o.AutoColor = true
+	}
+}
+
+// WithWxaCodeLineColor 设置线条颜色，与WithWxaCodeAutoColor互斥
+func WithWxaCodeLineColor(r, g, b int) WxaCodeOption {
+	return func(o *wxaCodeOptions) {
+		o.AutoColor = false
+		o.LineColor = &WxaLineColor{R: r, G: g, B: b}
+	}
+}
+
+// WithWxaCodeHyaline 使用透明底色
+func WithWxaCodeHyaline() WxaCodeOption {
+	return func(o *wxaCodeOptions) {
+		o.IsHyaline = true
+	}
+}
+
+// WithWxaCodeEnvVersion 设置要打开的小程序版本，可选release、trial、develop
+func WithWxaCodeEnvVersion(v string) WxaCodeOption {
+	return func(o *wxaCodeOptions) {
+		o.EnvVersion = v
+	}
+}
+
+// WithWxaCodeCheckPath 是否检查page已发布，默认检查，未发布的页面需设为false
+func WithWxaCodeCheckPath(check bool) WxaCodeOption {
+	return func(o *wxaCodeOptions) {
+		o.CheckPath = &check
+	}
+}
+
+// checkWxaScene scene最大32个可见字符，仅支持数字、大小写英文及部分特殊字符
+func checkWxaScene(scene string) error {
+	if scene == "" || len(scene) > WxaCodeSceneMax {
+		return fmt.Errorf("wechat: scene length must be in 1-%d", WxaCodeSceneMax)
+	}
+	for _, c := range scene {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.ContainsRune("!#$&'()*+,/:;=?@-._~", c)) {
+			return fmt.Errorf("wechat: invalid scene character %q", c)
+		}
+	}
+	return nil
+}
+
+// WxaCodeUnlimitedCtx 获取无数量限制的小程序码，返回图片字节
+// scene在页面中通过options.scene获取，page为空时跳转主页，且不能带参数
+func (s *Server) WxaCodeUnlimitedCtx(ctx context.Context, scene, page string, opts ...WxaCodeOption) ([]byte, error) {
+	if err := checkWxaScene(scene); err != nil {
+		return nil, err
+	}
+	req := struct {
+		Scene string `json:"scene"`
+		Page  string `json:"page,omitempty"`
+		wxaCodeOptions
+	}{Scene: scene, Page: strings.TrimPrefix(page, "/")}
+	for _, opt := range opts {
+		opt(&req.wxaCodeOptions)
+	}
+	return s.wxaCodeCtx(ctx, WXAPIWxaCodeUnlimited, &req)
+}
+
+// WxaCodeCtx 获取小程序码，path可带参数，与WxaQrcodeCtx共享10万个的生成总数限制，适用于长期有效但数量较少的场景
+// width为0时使用默认值430
+func (s *Server) WxaCodeCtx(ctx context.Context, path string, width int) ([]byte, error) {
+	if err := checkWxaPath(path); err != nil {
+		return nil, err
+	}
+	return s.wxaCodeCtx(ctx, WXAPIWxaCode, &struct {
+		Path string `json:"path"`
+		wxaCodeOptions
+	}{Path: path, wxaCodeOptions: wxaCodeOptions{Width: width}})
+}
+
+// WxaQrcodeCtx 获取小程序二维码(非圆形小程序码)，数量限制同WxaCodeCtx，width为0时使用默认值430
+func (s *Server) WxaQrcodeCtx(ctx context.Context, path string, width int) ([]byte, error) {
+	if err := checkWxaPath(path); err != nil {
+		return nil, err
+	}
+	return s.wxaCodeCtx(ctx, WXAPIWxaQrcode, &struct {
+		Path  string `json:"path"`
+		Width int    `json:"width,omitempty"`
+	}{path, width})
+}
+
+// checkWxaPath path不能为空且最大128字节
+func checkWxaPath(path string) error {
+	if path == "" || len(path) > WxaCodePathMax {
+		return fmt.Errorf("wechat: path length must be in 1-%d", WxaCodePathMax)
+	}
+	return nil
+}
+
+// wxaCodeCtx 请求小程序码类接口，微信出错时返回json而非图片，此时返回*util.WechatError
+func (s *Server) wxaCodeCtx(ctx context.Context, uri string, obj interface{}) ([]byte, error) {
+	uri, err := s.withToken(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	b, err := util.PostJsonCtx(ctx, uri, obj)
+	if err != nil {
+		return nil, err
+	}
+	if err = util.CheckErrCode(b); err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return nil, fmt.Errorf("wechat: unexpected json response: %s", b)
+	}
+	return b, nil
+}
@@ -0,0 +1,95 @@
+package wechat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/esap/wechat/util"
+)
+
+// ErrWxaWatermark 解密数据的水印appid与小程序appid不符
+var ErrWxaWatermark = errors.New("wechat: watermark appid mismatch")
+
+// WxaWatermark 小程序开放数据水印
+type WxaWatermark struct {
+	AppId     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// WxaPhoneInfo 小程序用户手机号
+type WxaPhoneInfo struct {
+	PhoneNumber     string       `json:"phoneNumber"` // 带区号的手机号，境外手机号会有区号
+	PurePhoneNumber string       `json:"purePhoneNumber"`
+	CountryCode     string       `json:"countryCode"`
+	Watermark       WxaWatermark `json:"watermark"`
+}
+
+// WxaUserInfo 小程序用户信息
+type WxaUserInfo struct {
+	OpenId    string       `json:"openId"`
+	NickName  string       `json:"nickName"`
+	Gender    int          `json:"gender"` // 1-男，2-女，0-未知
+	Language  string       `json:"language"`
+	City      string       `json:"city"`
+	Province  string       `json:"province"`
+	Country   string       `json:"country"`
+	AvatarUrl string       `json:"avatarUrl"`
+	UnionId   string       `json:"unionId"`
+	Watermark WxaWatermark `json:"watermark"`
+}
+
+// WxaDecrypt 用session_key解密小程序开放数据(encryptedData、iv均为base64)，
+// 数据带水印时校验appid，防止其他小程序的数据被重放
+func (s *Server) WxaDecrypt(sessionKey, encryptedData, iv string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	src, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+	ivb, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil {
+		return nil, err
+	}
+	b, err := util.AesCBCDecrypt(src, key, ivb)
+	if err != nil {
+		return nil, err
+	}
+	var w struct {
+		Watermark *WxaWatermark `json:"watermark"`
+	}
+	if json.Unmarshal(b, &w) == nil && w.Watermark != nil && w.Watermark.AppId != s.AppId {
+		return nil, ErrWxaWatermark
+	}
+	return b, nil
+}
+
+// WxaDecryptPhone 解密getPhoneNumber返回的手机号数据，新版推荐使用GetPhoneNumberCtx
+func (s *Server) WxaDecryptPhone(sessionKey, encryptedData, iv string) (*WxaPhoneInfo, error) {
+	info := new(WxaPhoneInfo)
+	if err := s.wxaDecryptTo(sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// WxaDecryptUserInfo 解密getUserInfo返回的用户信息
+func (s *Server) WxaDecryptUserInfo(sessionKey, encryptedData, iv string) (*WxaUserInfo, error) {
+	info := new(WxaUserInfo)
+	if err := s.wxaDecryptTo(sessionKey, encryptedData, iv, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// wxaDecryptTo 解密开放数据并解析到v
+func (s *Server) wxaDecryptTo(sessionKey, encryptedData, iv string, v interface{}) error {
+	b, err := s.WxaDecrypt(sessionKey, encryptedData, iv)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,129 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+)
+
+// 小程序加密scheme及链接接口
+const (
+	WXAPIGenerateScheme  = WXAAPI + "generatescheme?access_token="
+	WXAPIQueryScheme     = WXAAPI + "queryscheme?access_token="
+	WXAPIGenerateUrlLink = WXAAPI + "generate_urllink?access_token="
+	WXAPIGenShortLink    = WXAAPI + "genwxashortlink?access_token="
+)
+
+// 小程序链接失效类型
+const (
+	LinkExpireTime     = 0 // 到期失效，使用ExpireTime
+	LinkExpireInterval = 1 // 间隔天数失效，使用ExpireInterval
+)
+
+// WxaLinkExpire 小程序链接失效设置，IsExpire为false时长期有效(有数量上限)
+type WxaLinkExpire struct {
+	IsExpire       bool  `json:"is_expire,omitempty"`
+	ExpireType     int   `json:"expire_type,omitempty"`
+	ExpireTime     int64 `json:"expire_time,omitempty"`     // 到期失效的时间戳，最长30天
+	ExpireInterval int   `json:"expire_interval,omitempty"` // 到期失效的天数，最长30天
+}
+
+// WxaJumpWxa 链接打开的小程序页面
+type WxaJumpWxa struct {
+	Path       string `json:"path"`  // 已发布的页面，为空时跳转主页
+	Query      string `json:"query"` // 最大1024个字符
+	EnvVersion string `json:"env_version,omitempty"`
+}
+
+// SchemeReq 生成scheme请求体
+type SchemeReq struct {
+	JumpWxa *WxaJumpWxa `json:"jump_wxa,omitempty"`
+	WxaLinkExpire
+}
+
+// GenerateSchemeCtx 生成小程序scheme码，返回weixin://dl/business/?t=格式的openlink，可在短信、邮件等微信外场景打开
+func (s *Server) GenerateSchemeCtx(ctx context.Context, req *SchemeReq) (string, error) {
+	ret := new(struct {
+		WxErr
+		OpenLink string `json:"openlink"`
+	})
+	if err := s.postCtx(ctx, WXAPIGenerateScheme, req, ret); err != nil {
+		return "", err
+	}
+	return ret.OpenLink, nil
+}
+
+// SchemeInfo scheme码信息
+type SchemeInfo struct {
+	AppId      string `json:"appid"`
+	Path       string `json:"path"`
+	Query      string `json:"query"`
+	CreateTime int64  `json:"create_time"`
+	ExpireTime int64  `json:"expire_time"`
+	EnvVersion string `json:"env_version"`
+}
+
+// SchemeQuota 长期有效scheme码的配额
+type SchemeQuota struct {
+	LongTimeUsed  int `json:"long_time_used"`
+	LongTimeLimit int `json:"long_time_limit"`
+}
+
+// SchemeQueryResp 查询scheme码返回体
+type SchemeQueryResp struct {
+	WxErr
+	SchemeInfo  SchemeInfo  `json:"scheme_info"`
+	SchemeQuota SchemeQuota `json:"scheme_quota"`
+}
+
+// QuerySchemeCtx 查询scheme码信息及长期有效scheme码的配额
+func (s *Server) QuerySchemeCtx(ctx context.Context, scheme string) (*SchemeQueryResp, error) {
+	if scheme == "" {
+		return nil, errors.New("wechat: empty scheme")
+	}
+	ret := new(SchemeQueryResp)
+	if err := s.postCtx(ctx, WXAPIQueryScheme, map[string]string{"scheme": scheme}, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// UrlLinkReq 生成URL Link请求体，失效设置同SchemeReq
+type UrlLinkReq struct {
+	Path       string `json:"path,omitempty"`
+	Query      string `json:"query,omitempty"`
+	EnvVersion string `json:"env_version,omitempty"`
+	WxaLinkExpire
+}
+
+// GenerateUrlLinkCtx 生成小程序URL Link，返回https://wxaurl.cn/格式的链接，可在微信内外打开
+func (s *Server) GenerateUrlLinkCtx(ctx context.Context, req *UrlLinkReq) (string, error) {
+	ret := new(struct {
+		WxErr
+		UrlLink string `json:"url_link"`
+	})
+	if err := s.postCtx(ctx, WXAPIGenerateUrlLink, req, ret); err != nil {
+		return "", err
+	}
+	return ret.UrlLink, nil
+}
+
+// GenerateShortLinkCtx 生成小程序Short Link，仅能在微信内打开，pageUrl可带参数
+// isPermanent为true时长期有效(有数量上限)，否则有效期30天
+func (s *Server) GenerateShortLinkCtx(ctx context.Context, pageUrl, pageTitle string, isPermanent bool) (string, error) {
+	if pageUrl == "" {
+		return "", errors.New("wechat: empty page_url")
+	}
+	req := map[string]interface{}{
+		"page_url":     pageUrl,
+		"page_title":   pageTitle,
+		"is_permanent": isPermanent,
+	}
+	ret := new(struct {
+		WxErr
+		Link string `json:"link"`
+	})
+	if err := s.postCtx(ctx, WXAPIGenShortLink, req, ret); err != nil {
+		return "", err
+	}
+	return ret.Link, nil
+}
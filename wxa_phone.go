@@ -0,0 +1,27 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+)
+
+// WXAPIGetPhoneNumber 小程序手机号快速验证接口
+const (
+	WXAPIGetPhoneNumber = WXAAPI + "business/getuserphonenumber?access_token="
+)
+
+// GetPhoneNumberCtx 用getPhoneNumber按钮返回的code换取用户手机号，无需session_key解密，推荐使用
+// code有效期5分钟且只能使用一次，code无效可通过util.IsErrCode(err, 40029)判断
+func (s *Server) GetPhoneNumberCtx(ctx context.Context, code string) (*WxaPhoneInfo, error) {
+	if code == "" {
+		return nil, errors.New("wechat: empty phone code")
+	}
+	ret := new(struct {
+		WxErr
+		PhoneInfo WxaPhoneInfo `json:"phone_info"`
+	})
+	if err := s.postCtx(ctx, WXAPIGetPhoneNumber, map[string]string{"code": code}, ret); err != nil {
+		return nil, err
+	}
+	return &ret.PhoneInfo, nil
+}
@@ -0,0 +1,166 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+
+	"github.com/esap/wechat/util"
+)
+
+// WXAPIMsgSecCheck 小程序内容安全接口
+const (
+	WXAPIMsgSecCheck     = WXAAPI + "msg_sec_check?access_token="
+	WXAPIImgSecCheck     = WXAAPI + "img_sec_check?access_token="
+	WXAPIMediaCheckAsync = WXAAPI + "media_check_async?access_token="
+	EventWxaMediaCheck   = "wxa_media_check" // 异步检测结果推送事件
+)
+
+// 异步检测的多媒体类型
+const (
+	SecMediaAudio = 1
+	SecMediaImage = 2
+)
+
+// 内容安全检测场景
+const (
+	SecSceneProfile = 1 // 资料
+	SecSceneComment = 2 // 评论
+	SecSceneForum   = 3 // 论坛
+	SecSceneSocial  = 4 // 社交日志
+)
+
+// 内容安全检测建议
+const (
+	SecSuggestPass   = "pass"   // 通过
+	SecSuggestReview = "review" // 建议人工审核
+	SecSuggestRisky  = "risky"  // 有风险
+)
+
+// MsgSecCheckReq 文本内容安全检测请求体(v2)，OpenId需为近两小时访问过小程序的用户
+type MsgSecCheckReq struct {
+	Content   string `json:"content"`
+	Version   int    `json:"version"` // 为0时使用2
+	Scene     int    `json:"scene"`
+	OpenId    string `json:"openid"`
+	Title     string `json:"title,omitempty"`
+	Nickname  string `json:"nickname,omitempty"`
+	Signature string `json:"signature,omitempty"` // 仅scene为1时有效
+}
+
+// SecCheckResult 内容安全综合结果
+type SecCheckResult struct {
+	Suggest string `json:"suggest" xml:"suggest"`
+	Label   int    `json:"label" xml:"label"` // 100正常，10001广告，20001时政，20002色情，20003辱骂，20006违法犯罪，20008欺诈，20012低俗，20013版权，21000其他
+}
+
+// SecCheckDetail 内容安全各策略结果
+type SecCheckDetail struct {
+	Strategy string `json:"strategy" xml:"strategy"`
+	ErrCode  int    `json:"errcode" xml:"errcode"`
+	Suggest  string `json:"suggest" xml:"suggest"`
+	Label    int    `json:"label" xml:"label"`
+	Keyword  string `json:"keyword" xml:"keyword"`
+	Prob     int    `json:"prob" xml:"prob"` // 0-100，越高越可能违规
+}
+
+// MsgSecCheckResp 文本内容安全检测返回体
+type MsgSecCheckResp struct {
+	WxErr
+	TraceId string           `json:"trace_id"`
+	Result  SecCheckResult   `json:"result"`
+	Detail  []SecCheckDetail `json:"detail"`
+}
+
+// MsgSecCheckCtx 检测文本是否含有违法违规内容，结果见Result.Suggest
+// 旧版接口的违规内容可通过util.IsErrCode(err, 87014)判断
+func (s *Server) MsgSecCheckCtx(ctx context.Context, req *MsgSecCheckReq) (*MsgSecCheckResp, error) {
+	if req.Content == "" || req.OpenId == "" {
+		return nil, errors.New("wechat: content and openid required")
+	}
+	if req.Version == 0 {
+		r := *req
+		r.Version = 2
+		req = &r
+	}
+	ret := new(MsgSecCheckResp)
+	if err := s.postCtx(ctx, WXAPIMsgSecCheck, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ImgSecResp 图片内容安全检测返回体
+type ImgSecResp struct {
+	WxErr
+}
+
+// ImgSecCheckCtx 同步检测图片是否含有违法违规内容，图片不超过750px*1334px
+// 含有违规内容时返回errcode 87014，可通过util.IsErrCode(err, 87014)判断
+func (s *Server) ImgSecCheckCtx(ctx context.Context, filename string, r io.Reader) (*ImgSecResp, error) {
+	ret := new(ImgSecResp)
+	if err := s.uploadCtx(ctx, WXAPIImgSecCheck, []util.MultipartFormField{fileField("media", filename, r)}, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// MediaCheckAsyncCtx 异步检测图片或音频，mediaType为SecMediaImage或SecMediaAudio，返回trace_id
+// 检测结果在30分钟内以wxa_media_check事件推送，可用ParseMediaCheckEvent解析
+func (s *Server) MediaCheckAsyncCtx(ctx context.Context, mediaUrl string, mediaType, scene int, openId string) (string, error) {
+	if mediaUrl == "" || openId == "" {
+		return "", errors.New("wechat: media_url and openid required")
+	}
+	req := map[string]interface{}{
+		"media_url":  mediaUrl,
+		"media_type": mediaType,
+		"version":    2,
+		"scene":      scene,
+		"openid":     openId,
+	}
+	ret := new(struct {
+		WxErr
+		TraceId string `json:"trace_id"`
+	})
+	if err := s.postCtx(ctx, WXAPIMediaCheckAsync, req, ret); err != nil {
+		return "", err
+	}
+	return ret.TraceId, nil
+}
+
+// MediaCheckEvent 异步检测结果推送
+type MediaCheckEvent struct {
+	ToUserName   string           `json:"ToUserName" xml:"ToUserName"`
+	FromUserName string           `json:"FromUserName" xml:"FromUserName"`
+	CreateTime   int64            `json:"CreateTime" xml:"CreateTime"`
+	MsgType      string           `json:"MsgType" xml:"MsgType"`
+	Event        string           `json:"Event" xml:"Event"`
+	AppId        string           `json:"appid" xml:"appid"`
+	TraceId      string           `json:"trace_id" xml:"trace_id"`
+	Version      int              `json:"version" xml:"version"`
+	Result       SecCheckResult   `json:"result" xml:"result"`
+	Detail       []SecCheckDetail `json:"detail" xml:"detail"`
+	ErrCode      int              `json:"errcode" xml:"errcode"`
+	ErrMsg       string           `json:"errmsg" xml:"errmsg"`
+}
+
+// ParseMediaCheckEvent 解析已解密的异步检测结果推送，支持json及xml格式
+func ParseMediaCheckEvent(body []byte) (*MediaCheckEvent, error) {
+	e := new(MediaCheckEvent)
+	var err error
+	if b := bytes.TrimSpace(body); len(b) > 0 && b[0] == '{' {
+		err = json.Unmarshal(b, e)
+	} else {
+		err = xml.Unmarshal(b, e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Event != EventWxaMediaCheck {
+		return nil, errors.New("wechat: not a wxa_media_check event: " + e.Event)
+	}
+	return e, nil
+}
@@ -0,0 +1,35 @@
+package wechat
+
+import "context"
+
+// WXAPISubscribeSend 小程序订阅消息接口
+const (
+	WXAPISubscribeSend = WXAPI + "message/subscribe/send?access_token="
+)
+
+// 小程序订阅消息跳转的小程序版本
+const (
+	MiniprogramStateDeveloper = "developer" // 开发版
+	MiniprogramStateTrial     = "trial"     // 体验版
+	MiniprogramStateFormal    = "formal"    // 正式版
+)
+
+// WxaSubscribeValue 订阅消息字段值，与模板消息不同，不支持颜色
+type WxaSubscribeValue struct {
+	Value string `json:"value"`
+}
+
+// WxaSubscribeMsg 小程序订阅消息
+type WxaSubscribeMsg struct {
+	ToUser           string                       `json:"touser"`
+	TemplateId       string                       `json:"template_id"`
+	Page             string                       `json:"page,omitempty"`
+	Data             map[string]WxaSubscribeValue `json:"data"`
+	MiniprogramState string                       `json:"miniprogram_state,omitempty"` // 为空时默认正式版
+	Lang             string                       `json:"lang,omitempty"`              // zh_CN、en_US、zh_HK、zh_TW，默认zh_CN
+}
+
+// SendSubscribeCtx 发送小程序订阅消息，用户拒绝接收可通过util.IsErrCode(err, 43101)判断
+func (s *Server) SendSubscribeCtx(ctx context.Context, msg *WxaSubscribeMsg) error {
+	return s.postCtx(ctx, WXAPISubscribeSend, msg, nil)
+}
@@ -0,0 +1,62 @@
+package wechat
+
+import "testing"
+
+// 官方文档解密示例 https://developers.weixin.qq.com/miniprogram/dev/framework/open-ability/signature.html
+const (
+	wxaTestAppId      = "wx4f4bc4dec97d474b"
+	wxaTestSessionKey = "tiihtNczf5v6AKRyjwEUhQ=="
+	wxaTestIv         = "r7BXXKkLb8qrSNn05n0qiA=="
+	wxaTestData       = "CiyLU1Aw2KjvrjMdj8YKliAjtP4gsMZMQmRzooG2xrDcvSnxIMXFufNstNGTyaGS9uT5geRa0W4oTOb1WT7fJlAC+oNPdbB+3hVbJSRgv+4lGOETKUQz6OYStslQ142dNCuabNPGBzlooOmB231qMM85d2/fV6ChevvXvQP8Hkue1poOFtnEtpyxVLW1zAo6/1Xx1COxFvrc2d7UL/lmHInNlxuacJXwu0fjpXfz/YqYzBIBzD6WUfTIF9GRHpOn/Hz7saL8xz+W//FRAUid1OksQaQx4CMs8LOddcQhULW4ucetDf96JcR3g0gfRK4PC7E/r7Z6xNrXd2UIeorGj5Ef7b1pJAYB6Y5anaHqZ9J6nKEBvB4DnNLIVWSgARns/8wR2SiRS7MNACwTyrGvt9ts8p12PKFdlqYTopNHR1Vf7XjfhQlVsAJdNiKdYmYVoKlaRv85IfVunYzO0IKXsyl7JCUjCpoG20f0a04COwfneQAGGwd5oa+T8yO5hzuyDb/XcxxmK01EpqOyuxINew=="
+)
+
+func TestWxaDecryptUserInfo(t *testing.T) {
+	s := &Server{AppId: wxaTestAppId}
+	info, err := s.WxaDecryptUserInfo(wxaTestSessionKey, wxaTestData, wxaTestIv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.OpenId != "oGZUI0egBJY1zhBYw2KhdUfwVJJE" || info.NickName != "Band" || info.UnionId != "ocMvos6NjeKLIBqg5Mr9QjxrP1FA" {
+		t.Errorf("unexpected user info: %+v", info)
+	}
+	if info.Watermark.AppId != wxaTestAppId || info.Watermark.Timestamp != 1477314187 {
+		t.Errorf("unexpected watermark: %+v", info.Watermark)
+	}
+}
+
+func TestWxaDecryptWatermark(t *testing.T) {
+	s := &Server{AppId: "wx0000000000000000"}
+	if _, err := s.WxaDecrypt(wxaTestSessionKey, wxaTestData, wxaTestIv); err != ErrWxaWatermark {
+		t.Errorf("expected ErrWxaWatermark, got %v", err)
+	}
+}
+
+func TestWxaDecryptWrongKey(t *testing.T) {
+	s := &Server{AppId: wxaTestAppId}
+	if _, err := s.WxaDecrypt("AAAAAAAAAAAAAAAAAAAAAA==", wxaTestData, wxaTestIv); err == nil {
+		t.Error("expected error with wrong session_key")
+	}
+}
+
+func TestParseMediaCheckEvent(t *testing.T) {
+	body := `<xml><ToUserName><![CDATA[gh_38cc49f9733b]]></ToUserName><FromUserName><![CDATA[oH1fu0FdHqpToe2T6gBj0WyB8iS1]]></FromUserName>` +
+		`<CreateTime>1626959646</CreateTime><MsgType><![CDATA[event]]></MsgType><Event><![CDATA[wxa_media_check]]></Event>` +
+		`<appid><![CDATA[wx8f16a5e6c1042633]]></appid><trace_id><![CDATA[60f96f1d-3845297a-1976a3ae]]></trace_id><version>2</version>` +
+		`<detail><strategy><![CDATA[content_model]]></strategy><errcode>0</errcode><suggest><![CDATA[pass]]></suggest><label>100</label><prob>90</prob></detail>` +
+		`<errcode>0</errcode><errmsg><![CDATA[ok]]></errmsg><result><suggest><![CDATA[pass]]></suggest><label>100</label></result></xml>`
+	e, err := ParseMediaCheckEvent([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.TraceId != "60f96f1d-3845297a-1976a3ae" || e.Result.Suggest != SecSuggestPass || len(e.Detail) != 1 || e.Detail[0].Prob != 90 {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	e, err = ParseMediaCheckEvent([]byte(`{"Event":"wxa_media_check","trace_id":"t1","result":{"suggest":"risky","label":20002}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.TraceId != "t1" || e.Result.Suggest != SecSuggestRisky || e.Result.Label != 20002 {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}